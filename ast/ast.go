@@ -0,0 +1,170 @@
+// Package ast declares the types used to represent the syntax tree of expressions.
+
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Pos describes a position in the source expressions.
+type Pos struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number, starting at 1 (character count per line)
+}
+
+// Node is the interface implemented by all nodes of the syntax tree.
+type Node interface {
+	// Pos returns the position of the first character belonging to the node.
+	Pos() Pos
+	// String returns the node in expressions format, sub expressions are surrounded by parentheses.
+	String() string
+}
+
+var (
+	_ Node = new(Number)
+	_ Node = new(String)
+	_ Node = new(Bool)
+	_ Node = new(Constant)
+	_ Node = new(Unit)
+	_ Node = new(Variable)
+	_ Node = new(Binary)
+	_ Node = new(Unary)
+	_ Node = new(Call)
+	_ Node = new(Conditional)
+	_ Node = new(List)
+)
+
+// Number is a number literal, e.g. `1`, `1.5` or the imaginary `2i`.
+type Number struct {
+	ValuePos Pos
+	Literal  string
+}
+
+// String is a string literal, e.g. `"hello"` or `'hello'`, Value excludes the quotes.
+type String struct {
+	ValuePos Pos
+	Value    string
+}
+
+// Bool is a boolean literal, `true` or `false`.
+type Bool struct {
+	ValuePos Pos
+	Value    bool
+}
+
+// Constant is a predefined constant, e.g. `pi`.
+type Constant struct {
+	NamePos Pos
+	Name    string
+}
+
+// Unit is a unit of measure with optional integer exponent, e.g. `km` or `s^-2`, or a currency code, e.g. `USD`,
+// it is the quantity of one unit, so the number with unit `3 km` is the Binary `3 * km`.
+type Unit struct {
+	NamePos Pos
+	Name    string
+}
+
+// Variable is a variable reference, e.g. `$a`.
+type Variable struct {
+	Dollar Pos
+	Name   string
+}
+
+// Binary is an operation with two operands, e.g. `1+2`.
+type Binary struct {
+	X     Node
+	OpPos Pos
+	Op    string
+	Y     Node
+}
+
+// Unary is an operation with one operand, e.g. `-1`.
+type Unary struct {
+	OpPos Pos
+	Op    string
+	X     Node
+}
+
+// Call is a function call, e.g. `max(1,2)`.
+type Call struct {
+	NamePos Pos
+	Name    string
+	Args    []Node
+}
+
+// Conditional is a conditional expression, e.g. `$a > 0 ? 1 : 2`, only one of Then and Else is evaluated.
+type Conditional struct {
+	Cond     Node
+	Question Pos
+	Then     Node
+	Else     Node
+}
+
+// List is the values separated by comma, e.g. `1,2,3`.
+type List struct {
+	Elems []Node
+}
+
+func (n *Number) Pos() Pos      { return n.ValuePos }
+func (n *String) Pos() Pos      { return n.ValuePos }
+func (n *Bool) Pos() Pos        { return n.ValuePos }
+func (n *Constant) Pos() Pos    { return n.NamePos }
+func (n *Unit) Pos() Pos        { return n.NamePos }
+func (n *Variable) Pos() Pos    { return n.Dollar }
+func (n *Binary) Pos() Pos      { return n.X.Pos() }
+func (n *Unary) Pos() Pos       { return n.OpPos }
+func (n *Call) Pos() Pos        { return n.NamePos }
+func (n *Conditional) Pos() Pos { return n.Cond.Pos() }
+func (n *List) Pos() Pos        { return n.Elems[0].Pos() }
+
+func (n *Number) String() string   { return n.Literal }
+func (n *String) String() string   { return `"` + n.Value + `"` }
+func (n *Bool) String() string     { return strconv.FormatBool(n.Value) }
+func (n *Constant) String() string { return n.Name }
+func (n *Unit) String() string     { return n.Name }
+func (n *Variable) String() string { return "$" + n.Name }
+func (n *Binary) String() string   { return "(" + n.X.String() + " " + n.Op + " " + n.Y.String() + ")" }
+func (n *Unary) String() string    { return "(" + n.Op + n.X.String() + ")" }
+func (n *Call) String() string     { return n.Name + "(" + join(n.Args) + ")" }
+func (n *Conditional) String() string {
+	return "(" + n.Cond.String() + " ? " + n.Then.String() + " : " + n.Else.String() + ")"
+}
+func (n *List) String() string { return "(" + join(n.Elems) + ")" }
+
+func join(nodes []Node) string {
+	ss := make([]string, len(nodes))
+	for i, n := range nodes {
+		ss[i] = n.String()
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+// Inspect traverses the syntax tree in depth-first order, it calls f(node) for each node,
+// the children of node are skipped if f returns false.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+
+	var children []Node
+	switch n := node.(type) {
+	case *Binary:
+		children = []Node{n.X, n.Y}
+	case *Unary:
+		children = []Node{n.X}
+	case *Call:
+		children = n.Args
+	case *Conditional:
+		children = []Node{n.Cond, n.Then, n.Else}
+	case *List:
+		children = n.Elems
+	}
+
+	for _, c := range children {
+		Inspect(c, f)
+	}
+}
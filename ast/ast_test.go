@@ -0,0 +1,35 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	// sum($a, -1) * 2
+	node := &Binary{
+		X: &Call{Name: "sum", Args: []Node{
+			&Variable{Name: "a"},
+			&Unary{Op: "-", X: &Number{Literal: "1"}},
+		}},
+		Op: "*",
+		Y:  &Number{Literal: "2"},
+	}
+
+	if s := node.String(); s != "(sum($a, (-1)) * 2)" {
+		t.Errorf("unexpected string: %s", s)
+	}
+
+	var visited []string
+	Inspect(node, func(n Node) bool {
+		visited = append(visited, n.String())
+		// skip the children of unary
+		_, ok := n.(*Unary)
+		return !ok
+	})
+
+	expected := []string{"(sum($a, (-1)) * 2)", "sum($a, (-1))", "$a", "(-1)", "2"}
+	if !reflect.DeepEqual(expected, visited) {
+		t.Errorf("expected: %v, got: %v", expected, visited)
+	}
+}
@@ -3,274 +3,215 @@
 package calc
 
 import (
-	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
-	"text/scanner"
+	"time"
 
+	"github.com/xwjdsh/calc/ast"
+	"github.com/xwjdsh/calc/decimal"
 	"github.com/xwjdsh/calc/operator"
-	"github.com/xwjdsh/calc/stack"
 )
 
 var defaultCalculator = New()
 
 // Calculator wraps the logic of executing expressions.
+// It keeps no state between calls, so it is safe for concurrent use by multiple goroutines.
 type Calculator struct {
-	paramStack    *stack.Stack
-	operatorStack *stack.Stack
-	opManager     *operator.Manager
+	opManager *operator.Manager
+	options   options
 }
 
-// New returns a new Calculator instance.
-func New() *Calculator {
-	return &Calculator{
-		paramStack:    stack.New(),
-		operatorStack: stack.New(),
-		opManager:     operator.NewManager(),
-	}
+type options struct {
+	caseInsensitiveVariables bool
+	numbers                  operator.NumberType
 }
 
-// Must cause panic if err is not nil.
-func Must(r interface{}, err error) interface{} {
-	if err != nil {
-		panic(err)
-	}
+// Option configures a Calculator.
+type Option func(*Calculator)
 
-	return r
+// WithCaseInsensitiveVariables makes variable names case-insensitive,
+// the variable is looked up by exact name first, then lower case name, then any name equals under case-folding.
+func WithCaseInsensitiveVariables() Option {
+	return func(c *Calculator) {
+		c.options.caseInsensitiveVariables = true
+	}
 }
 
-// Eval calculates given expressions.
-func Eval(str string, m map[string]interface{}) (interface{}, error) {
-	return defaultCalculator.Eval(str, m)
+// WithDecimal evaluates numbers as exact decimal.Decimal, e.g. `0.1+0.2` is exactly `0.3`,
+// the inexact results like `1/3` are rounded to precision digits after the decimal point by rounding mode.
+func WithDecimal(precision int32, rounding decimal.RoundingMode) Option {
+	return func(c *Calculator) {
+		c.options.numbers = operator.DecimalNumbers
+		ctx := c.opManager.Context()
+		ctx.Numbers = operator.DecimalNumbers
+		ctx.DecimalPrecision = precision
+		ctx.Rounding = rounding
+	}
 }
 
-func (c *Calculator) Eval(input string, m map[string]interface{}) (interface{}, error) {
-	c.operatorStack.Clear()
-	c.paramStack.Clear()
-
-	var s scanner.Scanner
-	s.Init(strings.NewReader(strings.ToLower(input)))
-	// ignore scanner error message
-	s.Error = func(s *scanner.Scanner, msg string) {}
-
-	if m == nil {
-		m = map[string]interface{}{}
+// WithRational evaluates numbers as exact *big.Rat, e.g. `1/3*3` is exactly `1`,
+// the inexact functions like `sin` fall back to float64.
+func WithRational() Option {
+	return func(c *Calculator) {
+		c.options.numbers = operator.RationalNumbers
+		c.opManager.Context().Numbers = operator.RationalNumbers
 	}
+}
 
-	var (
-		preIsOperator *bool
-		parseVariable bool
-	)
-	for {
-		tok := s.Scan()
-		text := s.TokenText()
-		if tok == scanner.EOF {
-			break
-		}
-
-		//fmt.Printf("%s\t%s\n", scanner.TokenString(tok), text)
-		//fmt.Println("operator: ", c.operatorStack.String())
-		//fmt.Println("params: ", c.paramStack.String())
-
-		op, isOperator := c.opManager.GetByString(text)
-		switch {
-		case parseVariable:
-			v, ok := m[text]
-			if !ok {
-				return nil, fmt.Errorf("calc: unknown variable: %s", text)
-			}
-
-			if nv, ok := convertAndValidation(v); ok {
-				c.paramStack.Push(nv)
-			} else {
-				return nil, fmt.Errorf("calc: unsupported variable type, name: %s, type: %T", text, v)
-			}
-			parseVariable = false
-		case tok == scanner.Float || tok == scanner.Int:
-			// is number
-			f, err := strconv.ParseFloat(text, 64)
-			if err != nil {
-				return nil, err
-			}
-			c.paramStack.Push(f)
-		case tok == scanner.Char || tok == scanner.String:
-			// is string
-			// remove surrounding double or single quotes
-			c.paramStack.Push(text[1 : len(text)-1])
-		case isOperator:
-			// is operator
-			switch op.Type() {
-			case operator.General:
-				if err := c.handleGeneralOperator(op, preIsOperator); err != nil {
-					return nil, err
-				}
-			case operator.Bracket:
-				if err := c.handleBracketOperator(op); err != nil {
-					return nil, err
-				}
-			case operator.Function:
-				c.operatorStack.Push(op)
-			}
-		case text == "$":
-			parseVariable = true
-		default:
-			return nil, fmt.Errorf("calc: unsupported token: '%s'", text)
-		}
-
-		preIsOperator = &isOperator
+// WithIntegers keeps integer literals and variables as int64, or *big.Int if they overflow int64,
+// e.g. `9007199254740993+1` is exact. The division determines the result of dividing integers,
+// the integers mixed with float64 are promoted to float64.
+func WithIntegers(division operator.Division) Option {
+	return func(c *Calculator) {
+		c.options.numbers = operator.IntegerNumbers
+		ctx := c.opManager.Context()
+		ctx.Numbers = operator.IntegerNumbers
+		ctx.Division = division
 	}
+}
 
-	if err := c.executeAll(); err != nil {
-		return nil, err
+// WithAngleUnit sets the unit of the arguments of trigonometric functions and the results of inverse ones,
+// e.g. `sin(90)` is 1 in operator.Degrees, the default unit is operator.Radians.
+func WithAngleUnit(unit operator.AngleUnit) Option {
+	return func(c *Calculator) {
+		c.opManager.Context().AngleUnit = unit
 	}
+}
 
-	result, ok := c.paramStack.Top()
-	if !ok {
-		return nil, fmt.Errorf("calc: unable to parse expressions")
+// WithClock sets the clock of `now()`, e.g. a fixed time for deterministic results, the default clock is time.Now.
+// The dates without zone like `date('2026-01-01')` are in the location of the clock.
+func WithClock(now func() time.Time) Option {
+	return func(c *Calculator) {
+		c.opManager.Context().Now = now
 	}
-
-	return result, nil
 }
 
-func (c *Calculator) handleGeneralOperator(op operator.Operator, preIsOperator *bool) error {
-	// need handle `-` specially, convert to opposite number function
-	// preIsOperator == nil, e.g. `-1+2`
-	// *preIsOperator is true, e.g. `2+ -1` or `(-1-2)`
-	if op.Token() == operator.SUB {
-		if preIsOperator == nil {
-			// `-1` -> `0-1`
-			c.paramStack.Push(0.0)
-		} else if *preIsOperator {
-			// `1--1` -> `1-opp(1)`
-			op, _ = c.opManager.Get(operator.OPP)
-		}
+// WithCaretXor makes `^` the bitwise exclusive or like C for the programmers, e.g. `0b1100 ^ 0b1010` is 6,
+// `**` is the exponent still.
+func WithCaretXor() Option {
+	return func(c *Calculator) {
+		c.opManager.Context().CaretXor = true
 	}
+}
 
-	for {
-		ok, err := c.executeLastWithCondition(func(lastOp operator.ExecutableOperator) (bool, error) {
-			return op.Preference() <= lastOp.Preference(), nil
-		})
-		if err != nil {
-			return err
-		}
-		if !ok {
-			break
-		}
+// WithRateProvider sets the exchange rates of money conversion, e.g. `100 USD in EUR`,
+// operator.StaticRates is the provider of fixed rates.
+func WithRateProvider(rates operator.RateProvider) Option {
+	return func(c *Calculator) {
+		c.opManager.Context().Rates = rates
 	}
-
-	c.operatorStack.Push(op)
-	return nil
 }
 
-func (c *Calculator) handleBracketOperator(op operator.Operator) error {
-	switch op.Token() {
-	case operator.LPAREN:
-		c.operatorStack.Push(op)
-	case operator.RPAREN:
-		for {
-			ok, err := c.executeLastWithCondition(nil)
-			if err != nil {
-				return err
-			}
-			if !ok {
-				break
-			}
-		}
-
-		// pop `(`
-		if _, ok := c.operatorStack.Pop(); !ok {
-			return errors.New("calc: can not find matching parenthesis")
-		}
-
-		// calculation if pre operator is function type
-		if _, err := c.executeLastWithCondition(func(lastOp operator.ExecutableOperator) (bool, error) {
-			return lastOp.Type() == operator.Function, nil
-		}); err != nil {
-			return err
-		}
+// New returns a new Calculator instance.
+func New(opts ...Option) *Calculator {
+	c := &Calculator{
+		opManager: operator.NewManager(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 
-	return nil
+	return c
 }
 
-func (c *Calculator) executeAll() error {
-	for {
-		op, ok := mustOperator(c.operatorStack.Pop())
-		if !ok {
-			return nil
-		}
+// Must cause panic if err is not nil.
+func Must(r interface{}, err error) interface{} {
+	if err != nil {
+		panic(err)
+	}
 
-		eop, ok := op.(operator.ExecutableOperator)
-		if !ok {
-			return fmt.Errorf("calc: unexecutable operator: %s", op.Token())
-		}
+	return r
+}
 
-		if err := c.execute(eop); err != nil {
-			return err
-		}
-	}
+// Eval calculates given expressions, it is safe for concurrent use.
+func Eval(str string, m map[string]interface{}) (interface{}, error) {
+	return defaultCalculator.Eval(str, m)
 }
 
-func (c *Calculator) execute(op operator.ExecutableOperator) error {
-	count := op.ArgsCount()
-	args := make([]interface{}, count)
-	for i := count - 1; i >= 0; i-- {
-		arg, ok := c.paramStack.Pop()
-		if !ok {
-			return fmt.Errorf("calc: no enough params for operator: %s", op.Token())
-		}
+// Compile parses given expressions into a Program by the default calculator.
+func Compile(str string) (*Program, error) {
+	return defaultCalculator.Compile(str)
+}
 
-		args[i] = arg
-	}
+// Parse parses given expressions into a syntax tree by the default calculator.
+func Parse(str string) (ast.Node, error) {
+	return defaultCalculator.Parse(str)
+}
 
-	result, err := op.Execute(args)
+// Eval calculates given expressions, it equals to Compile and then Program.Eval.
+func (c *Calculator) Eval(input string, m map[string]interface{}) (interface{}, error) {
+	p, err := c.Compile(input)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	c.paramStack.Push(result)
-	return nil
+	return p.Eval(m)
 }
 
-func (c *Calculator) executeLastWithCondition(conditionFunc func(lastOp operator.ExecutableOperator) (bool, error)) (bool, error) {
-	preOp, ok := mustOperator(c.operatorStack.Top())
-	if !ok {
-		return false, nil
-	}
-	eop, ok := preOp.(operator.ExecutableOperator)
-	if !ok {
-		return false, nil
-	}
-
-	if conditionFunc != nil {
-		ok, err := conditionFunc(eop)
-		if !ok || err != nil {
-			return ok, err
-		}
+// Compile parses given expressions into a Program, which could be evaluated many times.
+func (c *Calculator) Compile(input string) (*Program, error) {
+	node, err := c.Parse(input)
+	if err != nil {
+		return nil, err
 	}
 
-	mustOperator(c.operatorStack.Pop())
-	if err := c.execute(eop); err != nil {
-		return false, err
-	}
+	return newCompiler(c.opManager, c.options).compile(node)
+}
 
-	return true, nil
+// Parse parses given expressions into a syntax tree.
+func (c *Calculator) Parse(input string) (ast.Node, error) {
+	return newParser(c.opManager, input).parse()
 }
 
-func mustOperator(r interface{}, ok bool) (operator.Operator, bool) {
-	if !ok {
-		return nil, false
+// RegisterFunc adds a custom function to the calculator, the name is case-insensitive and it replaces
+// the builtin function with the same name except `opp`, which is the prefix minus, and the constants like `pi`.
+// The function accepts minArgs to maxArgs arguments, maxArgs is operator.Variadic if unlimited.
+// The results are converted like variables, the unsupported types and NaN or infinity are errors.
+// Programs compiled before keep using the previous functions.
+func (c *Calculator) RegisterFunc(name string, minArgs, maxArgs int, fn operator.Func) error {
+	lower := strings.ToLower(name)
+	switch lower {
+	case keywordTrue, keywordFalse, keywordIf:
+		return fmt.Errorf("calc: keyword could not be function name: %s", name)
+	case operator.OPP.String():
+		return fmt.Errorf("calc: reserved function name: %s", name)
+	}
+	if _, ok := constants[lower]; ok {
+		return fmt.Errorf("calc: constant could not be function name: %s", name)
 	}
 
-	return r.(operator.Operator), true
+	numbers := c.options.numbers
+	return c.opManager.Register(operator.NewFunction(name, minArgs, maxArgs, func(args ...interface{}) (interface{}, error) {
+		r, err := fn(args...)
+		if err != nil {
+			return nil, err
+		}
+
+		v, ok := convertAndValidation(r, numbers)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported result type: %T", operator.ErrInvalidArguments, r)
+		}
+		return v, nil
+	}))
 }
 
-func convertAndValidation(i interface{}) (interface{}, bool) {
+func convertAndValidation(i interface{}, numbers operator.NumberType) (interface{}, bool) {
 	var r interface{}
 	switch v := i.(type) {
 	case string:
 		r = v
+	case bool:
+		r = v
+	case decimal.Decimal:
+		r = v
+	case *big.Rat:
+		r = v
+	case *big.Int:
+		r = v
 	case float64:
 		r = v
 	case uint:
@@ -295,9 +236,64 @@ func convertAndValidation(i interface{}) (interface{}, bool) {
 		r = float64(v)
 	case float32:
 		r = float64(v)
+	case complex128:
+		r = v
+	case complex64:
+		r = complex128(v)
+	case time.Time:
+		r = v
+	case time.Duration:
+		r = v
+	case operator.Quantity, operator.Money:
+		r = v
 	default:
+		return convertList(i, numbers)
+	}
+
+	switch i.(type) {
+	case float32, float64:
+		// the float variables stay float64 in integer mode
+		if numbers == operator.IntegerNumbers {
+			return r, true
+		}
+	}
+
+	if f, ok := r.(float64); ok && numbers != operator.FloatNumbers && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		if v, err := numbers.Parse(numberString(i)); err == nil {
+			return v, true
+		}
+	}
+
+	return r, true
+}
+
+// convertList converts the slice or array variable to list, e.g. []int{1, 2} equals to `1,2`.
+func convertList(i interface{}, numbers operator.NumberType) (interface{}, bool) {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
 		return nil, false
 	}
 
+	r := make([]interface{}, v.Len())
+	for j := range r {
+		e, ok := convertAndValidation(v.Index(j).Interface(), numbers)
+		if !ok {
+			return nil, false
+		}
+		r[j] = e
+	}
+
 	return r, true
 }
+
+// numberString returns the exact string of the integer, or the shortest string of the float, e.g. `0.1`.
+func numberString(i interface{}) string {
+	switch n := i.(type) {
+	case float64:
+		return strconv.FormatFloat(n, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(n), 'g', -1, 32)
+	}
+
+	return fmt.Sprint(i)
+}
@@ -1,9 +1,18 @@
 package calc
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"math/big"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/xwjdsh/calc/decimal"
+	"github.com/xwjdsh/calc/operator"
 )
 
 func TestEval(t *testing.T) {
@@ -25,6 +34,163 @@ func TestEval(t *testing.T) {
 		{expressions: `'hello' +  " " + 'world'`, expected: "hello world"},
 		{expressions: `'hello ' * 2 + "world"`, expected: "hello hello world"},
 
+		// comparison and boolean
+		{expressions: "1+1 == 2", expected: true},
+		{expressions: "1 != 1", expected: false},
+		{expressions: "1 < 2 && 2 <= 2 && 3 > 2 && 3 >= 4", expected: false},
+		{expressions: "'a' < 'b' || false", expected: true},
+		{expressions: "!(1 > 2) == TRUE", expected: true},
+		{expressions: "!!true", expected: true},
+		{expressions: `$age >= 18 && $country == "de"`, m: map[string]interface{}{"age": 20, "country": "de"}, expected: true},
+		{expressions: `$enabled && !$blocked`, m: map[string]interface{}{"enabled": true, "blocked": true}, expected: false},
+		{expressions: "1 == 'a'", expectErr: true},
+		{expressions: "1 && true", expectErr: true},
+		{expressions: "!1", expectErr: true},
+		{expressions: "true < false", expectErr: true},
+
+		// conditional
+		{expressions: "$qty > 100 ? $price * 0.5 : $price", m: map[string]interface{}{"qty": 200, "price": 10}, expected: 5.0},
+		{expressions: "$qty > 100 ? $price * 0.5 : $price", m: map[string]interface{}{"qty": 10, "price": 10}, expected: 10.0},
+		{expressions: "1 > 2 ? 'a' : 2 > 1 ? 'b' : 'c'", expected: "b"},
+		{expressions: "if($x == 0, 0, 1/$x)", m: map[string]interface{}{"x": 0}, expected: 0.0},
+		{expressions: "IF($x == 0, 0, 1/$x)+1", m: map[string]interface{}{"x": 2}, expected: 1.5},
+		{expressions: "$x != 0 && 1/$x > 0", m: map[string]interface{}{"x": 0}, expected: false},
+		{expressions: "$x == 0 || 1/$x > 0", m: map[string]interface{}{"x": 0}, expected: true},
+		{expressions: "true && 1", expectErr: true},
+		{expressions: "1 ? 2 : 3", expectErr: true},
+		{expressions: "if(true, 1)", expectErr: true},
+		{expressions: "true ? 1", expectErr: true},
+
+		// exponent
+		{expressions: "2^3^2", expected: 512.0},
+		{expressions: "2**3**2", expected: 512.0},
+		{expressions: "2*3^2", expected: 18.0},
+		{expressions: "-2^2", expected: -4.0},
+		{expressions: "2^-1", expected: 0.5},
+		{expressions: "(2^3)^2", expected: 64.0},
+		{expressions: "'a'^2", expectErr: true},
+
+		// complex
+		{expressions: "2+3i", expected: complex(2, 3)},
+		{expressions: "(1+2i)*(1-2i)", expected: complex(5, 0)},
+		{expressions: "sqrt(-1)", expected: complex(0, 1)},
+		{expressions: "sqrt(4)", expected: 2.0},
+		{expressions: "sqrt(-4) == 2i", expected: true},
+		{expressions: "abs(3+4i)", expected: 5.0},
+		{expressions: "conj(1+2i)", expected: complex(1, -2)},
+		{expressions: "re(1+2i)+im(1+2i)", expected: 3.0},
+		{expressions: "arg(1i)", expected: math.Pi / 2},
+		{expressions: "arg(-1)", expected: math.Pi},
+		{expressions: "exp(0i)", expected: complex(1, 0)},
+		{expressions: "exp(0)", expected: 1.0},
+		{expressions: "$z/2", m: map[string]interface{}{"z": complex64(complex(2, 4))}, expected: complex(1, 2)},
+		{expressions: "-$z", m: map[string]interface{}{"z": complex(1, 1)}, expected: complex(-1, -1)},
+		{expressions: "1i < 2i", expectErr: true},
+		{expressions: "1i % 2", expectErr: true},
+		{expressions: "1/0i", expectErr: true},
+		{expressions: "2 i", expectErr: true},
+
+		// math
+		{expressions: "pi", expected: math.Pi},
+		{expressions: "2*PI", expected: 2 * math.Pi},
+		{expressions: "E", expected: math.E},
+		{expressions: "cbrt(27)+sqrt(16)", expected: 7.0},
+		{expressions: "ln(e)", expected: 1.0},
+		{expressions: "log(1000)", expected: 3.0},
+		{expressions: "log(8, 2)+log2(8)+log10(100)", expected: 8.0},
+		{expressions: "asin(1)", expected: math.Pi / 2},
+		{expressions: "acos(1)+atan(0)", expected: 0.0},
+		{expressions: "atan2(1, 1)", expected: math.Pi / 4},
+		{expressions: "sinh(0)+cosh(0)+tanh(0)", expected: 1.0},
+		{expressions: "floor(-1.5), ceil(-1.5), round(-1.5), trunc(-1.5)", expected: []interface{}{-2.0, -1.0, -2.0, -1.0}},
+		{expressions: "hypot(3, 4)", expected: 5.0},
+		{expressions: "mod(5.5, 2)", expected: 1.5},
+		{expressions: "mod(-7, 3)", expected: -1.0},
+		{expressions: "sign(-3), sign(0), sign(2)", expected: []interface{}{-1.0, 0.0, 1.0}},
+		{expressions: "ln(0)", expectErr: true},
+		{expressions: "sqrt(-1i*0)+ln(-1)", expectErr: true},
+		{expressions: "asin(2)", expectErr: true},
+		{expressions: "log(1, 1)", expectErr: true},
+		{expressions: "mod(1, 0)", expectErr: true},
+		{expressions: "atan2(1)", expectErr: true},
+		{expressions: "sign('a')", expectErr: true},
+		{expressions: "pi(1)", expectErr: true},
+
+		// statistics
+		{expressions: "avg(1,2,3,4), mean((1,2),3)", expected: []interface{}{2.5, 2.0}},
+		{expressions: "median(3,1,2), median(4,1,3,2)", expected: []interface{}{2.0, 2.5}},
+		{expressions: "mode(3,1,3,2,1)", expected: 1.0},
+		{expressions: "variance(2,4,4,4,5,5,7,9), pvariance(2,4,4,4,5,5,7,9)", expected: []interface{}{32.0 / 7, 4.0}},
+		{expressions: "pstddev(2,4,4,4,5,5,7,9)", expected: 2.0},
+		{expressions: "stddev(1,3)", expected: math.Sqrt(2)},
+		{expressions: "percentile($list, 50), percentile($list, 0), percentile($list, 100), percentile($list, 25)", m: map[string]interface{}{"list": []int{1, 2, 3, 4, 5}}, expected: []interface{}{3.0, 1.0, 5.0, 2.0}},
+		{expressions: "percentile((1,2), 25)", expected: 1.25},
+		{expressions: "count($list), product($list), range($list)", m: map[string]interface{}{"list": []float64{2, 8, 4}}, expected: []interface{}{3.0, 64.0, 6.0}},
+		{expressions: "count($list), sum($list), count(1i, 2)", m: map[string]interface{}{"list": []int{}}, expected: []interface{}{0.0, 0.0, 2.0}},
+		{expressions: "geomean(2, 8)", expected: 4.0},
+		{expressions: "sum($list)+max($list)", m: map[string]interface{}{"list": []interface{}{1, 2.5, uint8(3)}}, expected: 9.5},
+		{expressions: "max($names)", m: map[string]interface{}{"names": []string{"a", "c", "b"}}, expected: "c"},
+		{expressions: "avg('a', 1)", expectErr: true},
+		{expressions: "variance(1)", expectErr: true},
+		{expressions: "percentile((1,2), 101)", expectErr: true},
+		{expressions: "geomean(-1, 2)", expectErr: true},
+		{expressions: "median(1i, 2i)", expectErr: true},
+		{expressions: "avg($list)", m: map[string]interface{}{"list": []interface{}{1, nil}}, expectErr: true},
+
+		// financial
+		{expressions: "round2(pmt(0.05/12, 360, 100000))", expected: -536.82},
+		{expressions: "pmt(0, 10, 1000), round2(pmt(0.1, 2, 0, 210, 1))", expected: []interface{}{-100.0, -90.91}},
+		{expressions: "round2(pv(0.1, 2, -100))", expected: 173.55},
+		{expressions: "round2(fv(0.05/12, 120, -100)), fv(0, 10, -100, -1000)", expected: []interface{}{15528.23, 2000.0}},
+		{expressions: "round2(nper(0.05/12, -536.82, 100000))", expected: 360.0},
+		{expressions: "round2(rate(360, -536.82, 100000)*1200)", expected: 5.0},
+		{expressions: "round2(npv(0.1, -10000, 3000, 4200, 6800))", expected: 1188.44},
+		{expressions: "round2(irr(-70000, 12000, 15000, 18000, 21000, 26000)*100)", expected: 8.66},
+		{expressions: "round2(irr($flows), 4)", m: map[string]interface{}{"flows": []int{-100, 110}}, expected: 0.1},
+		{expressions: "round2(2.345), round2(2.355), round2(2.675), round2(1250, -2), round2(-0.125)", expected: []interface{}{2.34, 2.36, 2.68, 1200.0, -0.12}},
+		{expressions: "pmt(0.1, 10, 1000, 0, 2)", expectErr: true},
+		{expressions: "pmt(0.1, 10)", expectErr: true},
+		{expressions: "irr(1, 2)", expectErr: true},
+		{expressions: "round2('a')", expectErr: true},
+
+		// string
+		{expressions: "len('héllo'), len((1,2,3))", expected: []interface{}{5.0, 3.0}},
+		{expressions: "upper('abc') + lower('DEF')", expected: "ABCdef"},
+		{expressions: "trim('  a b  ') + trim('--a--', '-')", expected: "a ba"},
+		{expressions: "substr('hello', 1, 3), substr('hello', -3), substr('héllo', 1, 100)", expected: []interface{}{"ell", "llo", "éllo"}},
+		{expressions: "replace('a-b-c', '-', '+'), replace('a-b-c', '-', '+', 1)", expected: []interface{}{"a+b+c", "a+b-c"}},
+		{expressions: "contains('hello', 'ell'), contains((1,2,3), 4), contains($tags, 'b')", m: map[string]interface{}{"tags": []string{"a", "b"}}, expected: []interface{}{true, false, true}},
+		{expressions: "startswith('hello', 'he') && endswith('hello', 'lo')", expected: true},
+		{expressions: "split('a,b,c', ',')", expected: []interface{}{"a", "b", "c"}},
+		{expressions: "join(split('a,b,c', ','), '-'), join((1,2.5), ', ')", expected: []interface{}{"a-b-c", "1, 2.5"}},
+		{expressions: "indexof('héllo', 'l'), indexof('hello', 'x'), indexof((1,2,3), 2)", expected: []interface{}{2.0, -1.0, 1.0}},
+		{expressions: "pad('7', 3, '0') + '|' + pad('ab', -4) + '|'", expected: "007|ab  |"},
+		{expressions: "format('%s: %d items, %.2f%% done', $name, $n, 1/3*100)", m: map[string]interface{}{"name": "job", "n": 3}, expected: "job: 3 items, 33.33% done"},
+		{expressions: "'Total: ' + str($total) + ' EUR'", m: map[string]interface{}{"total": 12.5}, expected: "Total: 12.5 EUR"},
+		{expressions: "str(true), str(1i)", expected: []interface{}{"true", "(0+1i)"}},
+		{expressions: "num(' 1.5 ') * 2, num(3)", expected: []interface{}{3.0, 3.0}},
+		{expressions: "num('abc')", expectErr: true},
+		{expressions: "upper(1)", expectErr: true},
+		{expressions: "substr('abc', 'a')", expectErr: true},
+		{expressions: "pad('a', 3, 'xy')", expectErr: true},
+		{expressions: "len(pad('a', 1048576)), len(pad('a', -1048576))", expected: []interface{}{1048576.0, 1048576.0}},
+		{expressions: "pad('a', 1048577)", expectErr: true},
+		{expressions: "pad('a', -9223372036854775808)", expectErr: true},
+		{expressions: "pad('a', 1e18)", expectErr: true},
+		{expressions: "len(1)", expectErr: true},
+
+		// regular expression
+		{expressions: `matches($email, "^.*@corp\.com$"), matches($email, '^.*@corp\.org$')`, m: map[string]interface{}{"email": "bob@corp.com"}, expected: []interface{}{true, false}},
+		{expressions: "$code =~ '^[A-Z]{3}-\\d+$' && !('abc' =~ 'x')", m: map[string]interface{}{"code": "ABC-42"}, expected: true},
+		{expressions: "'a' =~ 'A' == false", expected: true},
+		{expressions: "regex_extract('order 42, 43', '\\d+'), regex_extract('a=1', '(\\w)=(\\d)', 2), regex_extract('x', 'y')", expected: []interface{}{"42", "1", ""}},
+		{expressions: "regex_extract('2021-03', '(?P<year>\\d+)-(?P<month>\\d+)', 'month')", expected: "03"},
+		{expressions: "regex_replace('a1b22', '\\d+', '#'), regex_replace('John Smith', '(\\w+) (\\w+)', '$2 $1')", expected: []interface{}{"a#b#", "Smith John"}},
+		{expressions: "matches('a', $pattern)", m: map[string]interface{}{"pattern": "[a"}, expectErr: true},
+		{expressions: "regex_extract('a', '(a)', 2)", expectErr: true},
+		{expressions: "regex_extract('a', 'a', 'name')", expectErr: true},
+		{expressions: "1 =~ 'a'", expectErr: true},
+
 		// bracket
 		{expressions: "(1+2)*3+4", expected: 13.0},
 		{expressions: "(1*(2+1))*((3+1)*4)", expected: 48.0},
@@ -42,10 +208,13 @@ func TestEval(t *testing.T) {
 		{expressions: "min('a','n','f')*2", expected: "aa"},
 		{expressions: "sum(max(-5,1,3,8),min(-5,1,3,8))", expected: 3.0},
 		{expressions: "pow(2,3-1)", expected: 4.0},
+		{expressions: "sum((1,2),3)", expected: 6.0},
 
 		// variable
 		{expressions: "sum(1+$b*$a,$c)", m: map[string]interface{}{"a": 1, "b": uint(2), "c": 3}, expected: 6.0},
 		{expressions: "$a*$b", m: map[string]interface{}{"a": "hello ", "b": 2}, expected: "hello hello "},
+		{expressions: `$UserID+"-"+'Hello'`, m: map[string]interface{}{"UserID": "U1"}, expected: "U1-Hello"},
+		{expressions: "$UserID", m: map[string]interface{}{"userid": "u1"}, expectErr: true},
 
 		// error conditions
 		{expressions: "2/0", expectErr: true},
@@ -54,6 +223,7 @@ func TestEval(t *testing.T) {
 		{expressions: `"test"-3`, expectErr: true},
 		{expressions: `"test"/3`, expectErr: true},
 		{expressions: `$a+1`, m: map[string]interface{}{"a": true}, expectErr: true},
+		{expressions: `$a+1`, m: map[string]interface{}{"a": []int{1}}, expectErr: true},
 	}
 
 	for _, c := range cases {
@@ -71,3 +241,870 @@ func TestEval(t *testing.T) {
 		}
 	}
 }
+
+func TestCompile(t *testing.T) {
+	p, err := Compile("$price*$qty-$discount")
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	cases := []struct {
+		m         map[string]interface{}
+		expected  interface{}
+		expectErr bool
+	}{
+		{m: map[string]interface{}{"price": 2.5, "qty": 4, "discount": 1}, expected: 9.0},
+		{m: map[string]interface{}{"price": 10, "qty": 3, "discount": 0.5}, expected: 29.5},
+		{m: map[string]interface{}{"price": 10, "qty": 3}, expectErr: true},
+	}
+
+	for _, c := range cases {
+		result, err := p.Eval(c.m)
+		if c.expectErr && err == nil {
+			t.Errorf("expect error, got nil, variables: %v", c.m)
+		}
+
+		if !c.expectErr && err != nil {
+			t.Errorf("expect no error, got %v, variables: %v", err, c.m)
+		}
+
+		if !reflect.DeepEqual(c.expected, result) {
+			t.Errorf("expected: %v, got: %v, variables: %v", c.expected, result, c.m)
+		}
+	}
+
+	for _, expressions := range []string{"1+", "1#2", ""} {
+		if _, err := Compile(expressions); err == nil {
+			t.Errorf("expect compile error, got nil, expressions: %s", expressions)
+		}
+	}
+}
+
+func TestConcurrentEval(t *testing.T) {
+	p := Must(Compile("sum($a,2,3)*$a+max($a,10)")).(*Program)
+	c := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				a := float64(i*200 + j)
+				m := map[string]interface{}{"a": a}
+				expected := (a+5)*a + math.Max(a, 10)
+
+				for _, f := range []func() (interface{}, error){
+					func() (interface{}, error) { return Eval("sum($a,2,3)*$a+max($a,10)", m) },
+					func() (interface{}, error) { return c.Eval("sum($a,2,3)*$a+max($a,10)", m) },
+					func() (interface{}, error) { return p.Eval(m) },
+				} {
+					result, err := f()
+					if err != nil {
+						t.Errorf("expect no error, got %v", err)
+						return
+					}
+					if result != expected {
+						t.Errorf("expected: %v, got: %v", expected, result)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		expressions string
+		expected    string
+		expectErr   bool
+	}{
+		{expressions: "1+2*3", expected: "(1 + (2 * 3))"},
+		{expressions: "1-2-3", expected: "((1 - 2) - 3)"},
+		{expressions: "--1", expected: "(-(-1))"},
+		{expressions: "2+ -1*3", expected: "(2 + ((-1) * 3))"},
+		{expressions: "!$a || $b && 1+1 >= 2 == true", expected: "((!$a) || ($b && (((1 + 1) >= 2) == true)))"},
+		{expressions: "(1+2)*$a", expected: "((1 + 2) * $a)"},
+		{expressions: "max(1,sin(2),'a')", expected: `max(1, sin(2), "a")`},
+		{expressions: "1,2+3", expected: "(1, (2 + 3))"},
+		{expressions: "$a > 1 ? 1 : $b ? 2 : 3", expected: "(($a > 1) ? 1 : ($b ? 2 : 3))"},
+		{expressions: "If(true, 1, 2)", expected: "if(true, 1, 2)"},
+		{expressions: "2^3**2*4", expected: "((2 ^ (3 ** 2)) * 4)"},
+		{expressions: "-2^2", expected: "(-(2 ^ 2))"},
+		{expressions: "2+3.5i", expected: "(2 + 3.5i)"},
+		{expressions: "2*Pi", expected: "(2 * pi)"},
+		{expressions: "3 km + 200 m to m", expected: "(((3 * km) + (200 * m)) to m)"},
+		{expressions: "9.81 m/s^2 in km/h^-2", expected: "(((9.81 * m) / s^2) in (km / h^-2))"},
+		{expressions: "$d min + min (1, 2)", expected: "(($d * min) + min(1, 2))"},
+		{expressions: "$a & 1 == 1 | 1 << 2 + 1", expected: "(($a & (1 == 1)) | (1 << (2 + 1)))"},
+		{expressions: "~0xFF & -$a >> 1", expected: "((~0xFF) & ((-$a) >> 1))"},
+		{expressions: "3 m^x", expectErr: true},
+		{expressions: "(1+2", expectErr: true},
+		{expressions: "1+", expectErr: true},
+		{expressions: "1 2", expectErr: true},
+		{expressions: "sin 1", expectErr: true},
+	}
+
+	for _, c := range cases {
+		node, err := Parse(c.expressions)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("expect error, got nil, expressions: %s", c.expressions)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c.expressions)
+			continue
+		}
+
+		if node.String() != c.expected {
+			t.Errorf("expected: %v, got: %v, expressions: %s", c.expected, node.String(), c.expressions)
+		}
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	c := New()
+	vat := func(args ...interface{}) (interface{}, error) {
+		rate := 0.2
+		if len(args) == 2 {
+			rate = args[1].(float64)
+		}
+
+		return args[0].(float64) * (1 + rate), nil
+	}
+	if err := c.RegisterFunc("VAT", 1, 2, vat); err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if err := c.RegisterFunc("If", 3, 3, vat); err == nil {
+		t.Fatalf("expect error for keyword, got nil")
+	}
+	for _, name := range []string{"opp", "PI", "e"} {
+		if err := c.RegisterFunc(name, 1, 1, vat); err == nil {
+			t.Fatalf("expect error for reserved name %s, got nil", name)
+		}
+	}
+	results := map[string]interface{}{"count": 3, "ratio": float32(0.5), "inf": float32(math.Inf(1)), "nan": math.NaN(), "chan": make(chan int)}
+	for name, r := range results {
+		r := r
+		if err := c.RegisterFunc(name, 0, 0, func(...interface{}) (interface{}, error) { return r, nil }); err != nil {
+			t.Fatalf("expect no error, got %v", err)
+		}
+	}
+
+	cases := []struct {
+		expressions string
+		expected    interface{}
+		expectErr   bool
+	}{
+		{expressions: "vat(100)", expected: 120.0},
+		{expressions: "Vat(100, 0.5)+1", expected: 151.0},
+		{expressions: "vat()", expectErr: true},
+		{expressions: "vat(1,2,3)", expectErr: true},
+		{expressions: "2 - -1, -vat(10)", expected: []interface{}{3.0, -12.0}},
+		{expressions: "count() + ratio()", expected: 3.5},
+		{expressions: "inf()", expectErr: true},
+		{expressions: "nan()", expectErr: true},
+		{expressions: "chan()", expectErr: true},
+	}
+
+	for _, c1 := range cases {
+		result, err := c.Eval(c1.expressions, nil)
+		if c1.expectErr && err == nil {
+			t.Errorf("expect error, got nil, expressions: %s", c1.expressions)
+		}
+
+		if !c1.expectErr && err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c1.expressions)
+		}
+
+		if !reflect.DeepEqual(c1.expected, result) {
+			t.Errorf("expected: %v, got: %v, expressions: %s", c1.expected, result, c1.expressions)
+		}
+	}
+
+	// functions are registered per calculator
+	if _, err := Eval("vat(100)", nil); err == nil {
+		t.Errorf("expect error for the default calculator, got nil")
+	}
+}
+
+func TestCaseInsensitiveVariables(t *testing.T) {
+	c := New(WithCaseInsensitiveVariables())
+	cases := []struct {
+		expressions string
+		m           map[string]interface{}
+		expected    interface{}
+		expectErr   bool
+	}{
+		{expressions: "$UserID", m: map[string]interface{}{"UserID": "a", "userid": "b"}, expected: "a"},
+		{expressions: "$UserID", m: map[string]interface{}{"userid": "b"}, expected: "b"},
+		{expressions: "$UserID", m: map[string]interface{}{"USERID": "c"}, expected: "c"},
+		{expressions: "$UserID", m: map[string]interface{}{"user": "d"}, expectErr: true},
+	}
+
+	for _, c1 := range cases {
+		result, err := c.Eval(c1.expressions, c1.m)
+		if c1.expectErr && err == nil {
+			t.Errorf("expect error, got nil, expressions: %s", c1.expressions)
+		}
+
+		if !c1.expectErr && err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c1.expressions)
+		}
+
+		if !reflect.DeepEqual(c1.expected, result) {
+			t.Errorf("expected: %v, got: %v, expressions: %s", c1.expected, result, c1.expressions)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	c := New(WithDecimal(4, decimal.HalfEven))
+	cases := []struct {
+		expressions string
+		m           map[string]interface{}
+		expected    string
+		expectErr   bool
+	}{
+		{expressions: "0.1+0.2", expected: "0.3"},
+		{expressions: "0.1+0.2 == 0.3", expected: "true"},
+		{expressions: "1.10*3", expected: "3.30"},
+		{expressions: "1/3", expected: "0.3333"},
+		{expressions: "2/3", expected: "0.6667"},
+		{expressions: "10/4", expected: "2.5"},
+		{expressions: "10%3", expected: "1"},
+		{expressions: "2^-2", expected: "0.25"},
+		{expressions: "-1.5+abs(-2)", expected: "0.5"},
+		{expressions: "max(0.1, 0.25, 0.2)", expected: "0.25"},
+		{expressions: "sum(0.1, 0.2, 0.3)", expected: "0.6"},
+		{expressions: "sin(1)", expected: "0.8415"},
+		{expressions: "4^0.5", expected: "2"},
+		{expressions: "'ab' * 2", expected: "abab"},
+		{expressions: "pi", expected: "3.1416"},
+		{expressions: "round(2.5), floor(-1.5), trunc(-1.5)", expected: "[3 -2 -1]"},
+		{expressions: "mod(5.5, 2)", expected: "1.5"},
+		{expressions: "sign(-0.5)", expected: "-1"},
+		{expressions: "sqrt(2)", expected: "1.4142"},
+		{expressions: "avg(0.1, 0.2, 0.4)", expected: "0.2333"},
+		{expressions: "percentile($list, 90)", m: map[string]interface{}{"list": []float64{0.1, 0.2, 0.3}}, expected: "0.28"},
+		{expressions: "pvariance(1, 2)", expected: "0.25"},
+		{expressions: "round2(pmt(0.005, 360, 100000))", expected: "-599.55"},
+		{expressions: "npv(0.1, 110, 121)", expected: "200"},
+		{expressions: "fv(0.1, 2, -100), fv(0.005, 120, -100), pv(0.1, 2, -100)", expected: "[210 16387.9347 173.5537]"},
+		{expressions: "round2(2.345), round2(2.355)", expected: "[2.34 2.36]"},
+		{expressions: "num('0.1') + 0.2", expected: "0.3"},
+		{expressions: "len('abc') / 3", expected: "1"},
+		{expressions: "$price * $qty", m: map[string]interface{}{"price": 0.1, "qty": 3}, expected: "0.3"},
+		{expressions: "$price * $qty", m: map[string]interface{}{"price": float32(0.1), "qty": int64(3)}, expected: "0.3"},
+		{expressions: "$a + 1", m: map[string]interface{}{"a": decimal.New(5, 1)}, expected: "1.5"},
+		{expressions: "0.5^100000, 1^100000, 2^100", expected: "[0 1 1267650600228229401496703205376]"},
+		{expressions: "9^9^9", expectErr: true},
+		{expressions: "2^2^40", expectErr: true},
+		{expressions: "1e2000000000", expectErr: true},
+		{expressions: "1e-2000000000 + 1", expectErr: true},
+		{expressions: "1/0", expectErr: true},
+		{expressions: "1%0", expectErr: true},
+	}
+
+	for _, c1 := range cases {
+		result, err := c.Eval(c1.expressions, c1.m)
+		if c1.expectErr {
+			if err == nil {
+				t.Errorf("expect error, got nil, expressions: %s", c1.expressions)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c1.expressions)
+			continue
+		}
+
+		switch result.(type) {
+		case decimal.Decimal, bool, string, []interface{}:
+		default:
+			t.Errorf("expect decimal result, got %T, expressions: %s", result, c1.expressions)
+		}
+
+		if s := fmt.Sprint(result); s != c1.expected {
+			t.Errorf("expected: %s, got: %s, expressions: %s", c1.expected, s, c1.expressions)
+		}
+	}
+
+	if r, err := New(WithDecimal(2, decimal.Floor)).Eval("-2/3", nil); err != nil || fmt.Sprint(r) != "-0.67" {
+		t.Errorf("expected: -0.67, got: %v, %v", r, err)
+	}
+}
+
+func TestRational(t *testing.T) {
+	c := New(WithRational())
+	cases := []struct {
+		expressions string
+		m           map[string]interface{}
+		expected    interface{}
+		expectErr   bool
+	}{
+		{expressions: "1/3*3", expected: big.NewRat(1, 1)},
+		{expressions: "1/3+1/6", expected: big.NewRat(1, 2)},
+		{expressions: "0.1+0.2", expected: big.NewRat(3, 10)},
+		{expressions: "1/3 == 2/6", expected: true},
+		{expressions: "7/2%1", expected: big.NewRat(1, 2)},
+		{expressions: "-7%3", expected: big.NewRat(-1, 1)},
+		{expressions: "(2/3)^-2", expected: big.NewRat(9, 4)},
+		{expressions: "abs(-1/3)", expected: big.NewRat(1, 3)},
+		{expressions: "max(1/3, 0.3)", expected: big.NewRat(1, 3)},
+		{expressions: "sum(1/3, 1/3, 1/3)", expected: big.NewRat(1, 1)},
+		{expressions: "floor(-7/2)", expected: big.NewRat(-4, 1)},
+		{expressions: "round(5/2)", expected: big.NewRat(3, 1)},
+		{expressions: "pi", expected: math.Pi},
+		{expressions: "sin(0)", expected: 0.0},
+		{expressions: "4^0.5", expected: 2.0},
+		{expressions: "1/3 + $f", m: map[string]interface{}{"f": 0.5}, expected: big.NewRat(5, 6)},
+		{expressions: "$a * 3", m: map[string]interface{}{"a": big.NewRat(1, 3)}, expected: big.NewRat(1, 1)},
+		{expressions: "$id + 1", m: map[string]interface{}{"id": int64(1) << 60}, expected: new(big.Rat).SetInt64(1<<60 + 1)},
+		{expressions: "(1/2)^-3", expected: big.NewRat(8, 1)},
+		{expressions: "0.5^100000", expected: 0.0},
+		{expressions: "count($list), sum($list)", m: map[string]interface{}{"list": []int{}}, expected: []interface{}{big.NewRat(0, 1), big.NewRat(0, 1)}},
+		{expressions: "round2(pmt(0.05/12, 360, 100000)), pmt(0, 4, 1000)", expected: []interface{}{-536.82, -250.0}},
+		{expressions: "1/0", expectErr: true},
+		{expressions: "0^-1", expectErr: true},
+		{expressions: "9^9^9", expectErr: true},
+		{expressions: "2^2^40", expectErr: true},
+	}
+
+	for _, c1 := range cases {
+		result, err := c.Eval(c1.expressions, c1.m)
+		if c1.expectErr {
+			if err == nil {
+				t.Errorf("expect error, got nil, expressions: %s", c1.expressions)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c1.expressions)
+			continue
+		}
+
+		if r, ok := c1.expected.(*big.Rat); ok {
+			if v, ok := result.(*big.Rat); !ok || v.Cmp(r) != 0 {
+				t.Errorf("expected: %v, got: %v, expressions: %s", r, result, c1.expressions)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(c1.expected, result) {
+			t.Errorf("expected: %v, got: %v, expressions: %s", c1.expected, result, c1.expressions)
+		}
+	}
+}
+
+func TestIntegers(t *testing.T) {
+	huge, _ := new(big.Int).SetString("9223372036854775808", 10)
+	cases := []struct {
+		division    operator.Division
+		expressions string
+		m           map[string]interface{}
+		expected    interface{}
+		expectErr   bool
+	}{
+		{expressions: "1+2", expected: int64(3)},
+		{expressions: "7%3", expected: int64(1)},
+		{expressions: "-7%3", expected: int64(-1)},
+		{expressions: "6/2", expected: int64(3)},
+		{expressions: "7/2", expected: 3.5},
+		{expressions: "7/2", division: operator.TruncatedDivision, expected: int64(3)},
+		{expressions: "-7/2", division: operator.TruncatedDivision, expected: int64(-3)},
+		{expressions: "-7/2", division: operator.FloorDivision, expected: int64(-4)},
+		{expressions: "avg(1,2)", division: operator.TruncatedDivision, expected: 1.5},
+		{expressions: "median(1,2)", division: operator.TruncatedDivision, expected: 1.5},
+		{expressions: "variance(1,2)", division: operator.TruncatedDivision, expected: 0.5},
+		{expressions: "percentile((1,2),25)", division: operator.TruncatedDivision, expected: 1.25},
+		{expressions: "avg(2,4)", division: operator.FloorDivision, expected: int64(3)},
+		{expressions: "count($list), sum($list), count(1i, 2)", m: map[string]interface{}{"list": []int{}}, expected: []interface{}{int64(0), int64(0), int64(2)}},
+		{expressions: "round2(pmt(0, 3, 1000)), pmt(0, 4, 1000), npv(1, 2, 4)", division: operator.TruncatedDivision, expected: []interface{}{-333.33, -250.0, 2.0}},
+		{expressions: "1+0.5", expected: 1.5},
+		{expressions: "2^10", expected: int64(1024)},
+		{expressions: "2^-1", expected: 0.5},
+		{expressions: "2^63", expected: huge},
+		{expressions: "3^4611686018427387904", expectErr: true},
+		{expressions: "9223372036854775807+1", expected: huge},
+		{expressions: "9223372036854775808-1", expected: int64(math.MaxInt64)},
+		{expressions: "abs(-3)+max(1,2)", expected: int64(5)},
+		{expressions: "sum(1,2,3)", expected: int64(6)},
+		{expressions: "sin(0)", expected: 0.0},
+		{expressions: "'ab'*2", expected: "abab"},
+		{expressions: "$id+1", m: map[string]interface{}{"id": int64(9007199254740993)}, expected: int64(9007199254740994)},
+		{expressions: "$id", m: map[string]interface{}{"id": uint64(math.MaxUint64)}, expected: new(big.Int).SetUint64(math.MaxUint64)},
+		{expressions: "$id == 9007199254740993", m: map[string]interface{}{"id": 9007199254740992}, expected: false},
+		{expressions: "$f*2", m: map[string]interface{}{"f": 1.5}, expected: 3.0},
+		{expressions: "$f*2", m: map[string]interface{}{"f": 2.0}, expected: 4.0},
+		{expressions: "1/0", expectErr: true},
+		{expressions: "1%0", expectErr: true},
+	}
+
+	for _, c1 := range cases {
+		result, err := New(WithIntegers(c1.division)).Eval(c1.expressions, c1.m)
+		if c1.expectErr {
+			if err == nil {
+				t.Errorf("expect error, got nil, expressions: %s", c1.expressions)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c1.expressions)
+			continue
+		}
+
+		if i, ok := c1.expected.(*big.Int); ok {
+			if v, ok := result.(*big.Int); !ok || v.Cmp(i) != 0 {
+				t.Errorf("expected: %v, got: %v, expressions: %s", i, result, c1.expressions)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(c1.expected, result) {
+			t.Errorf("expected: %v (%T), got: %v (%T), expressions: %s", c1.expected, c1.expected, result, result, c1.expressions)
+		}
+	}
+}
+
+func TestAngleUnit(t *testing.T) {
+	cases := []struct {
+		unit        operator.AngleUnit
+		expressions string
+		expected    interface{}
+		expectErr   bool
+	}{
+		{unit: operator.Radians, expressions: "sin(pi/2)", expected: 1.0},
+		{unit: operator.Degrees, expressions: "sin(90), cos(90), sin(-90), cos(180), sin(720)", expected: []interface{}{1.0, 0.0, -1.0, -1.0, 0.0}},
+		{unit: operator.Degrees, expressions: "round(sin(30)*1e9), round(tan(45)*1e9)", expected: []interface{}{5e8, 1e9}},
+		{unit: operator.Degrees, expressions: "asin(1), acos(-1), atan2(1, 0)", expected: []interface{}{90.0, 180.0, 90.0}},
+		{unit: operator.Gradians, expressions: "sin(100), asin(1)", expected: []interface{}{1.0, 100.0}},
+		{unit: operator.Degrees, expressions: "deg(pi), rad(180)", expected: []interface{}{180.0, math.Pi}},
+		{unit: operator.Radians, expressions: "deg(pi), rad(180)", expected: []interface{}{180.0, math.Pi}},
+		{unit: operator.Degrees, expressions: "tan(90)", expectErr: true},
+	}
+
+	for _, c := range cases {
+		result, err := New(WithAngleUnit(c.unit)).Eval(c.expressions, nil)
+		if c.expectErr && err == nil {
+			t.Errorf("expect error, got nil, expressions: %s", c.expressions)
+		}
+
+		if !c.expectErr && err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c.expressions)
+		}
+
+		if !reflect.DeepEqual(c.expected, result) {
+			t.Errorf("expected: %v, got: %v, unit: %s, expressions: %s", c.expected, result, c.unit, c.expressions)
+		}
+	}
+
+	if u, err := operator.ParseAngleUnit("Degrees"); err != nil || u != operator.Degrees {
+		t.Errorf("expected: deg, got: %v, %v", u, err)
+	}
+}
+
+func TestTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC)
+	m := map[string]interface{}{
+		"created":  time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC),
+		"deadline": time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC),
+		"timeout":  90 * time.Minute,
+	}
+
+	cases := []struct {
+		expressions string
+		numbers     Option
+		expected    interface{}
+		expectErr   bool
+	}{
+		{expressions: "now()", expected: now},
+		{expressions: "date('2026-01-01'), date(2026, 2, 30), date('2026-01-01 08:00:00')", expected: []interface{}{
+			time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC),
+		}},
+		{expressions: "$deadline - $created", expected: 6*24*time.Hour + 9*time.Hour},
+		{expressions: "hours($deadline - $created), days(now() - $created)", expected: []interface{}{153.0, 3.0208333333333335}},
+		{expressions: "$deadline - now() < days(4) && $deadline - now() > days(3)", expected: true},
+		{expressions: "now() + days(1.5) - hours(2), days(3) + $created", expected: []interface{}{
+			time.Date(2026, 10, 18, 19, 30, 0, 0, time.UTC), time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC),
+		}},
+		{expressions: "$timeout * 2, 2 * $timeout, $timeout / 3, -minutes(1), minutes($timeout) / 60", expected: []interface{}{
+			3 * time.Hour, 3 * time.Hour, 30 * time.Minute, -time.Minute, 1.5,
+		}},
+		{expressions: "$timeout / minutes(30), seconds(1) == minutes(1/60), weeks(1) == days(7)", expected: []interface{}{3.0, true, true}},
+		{expressions: "weekday(), weekday(date('2026-10-19'))", expected: []interface{}{6.0, 1.0}},
+		{expressions: "addmonths(date('2026-01-31'), 1), addmonths(date('2026-03-31'), -13)", expected: []interface{}{
+			time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+		}},
+		{expressions: "format_date(now()), format_date($deadline, 'Jan 2 15:04'), str(date('2026-01-01'))", expected: []interface{}{
+			"2026-10-17", "Oct 20 18:00", "2026-01-01T00:00:00Z",
+		}},
+		{expressions: "contains(($created, $deadline), date(2026, 10, 14) + hours(9))", expected: true},
+		{expressions: "str(hours($timeout)), $deadline - $created == days(6) + hours(9)", numbers: WithDecimal(4, decimal.HalfEven), expected: []interface{}{"1.5", true}},
+		{expressions: "days(1) * 1.5 / 2, weekday()", numbers: WithIntegers(operator.FloatDivision), expected: []interface{}{18 * time.Hour, int64(6)}},
+		{expressions: "hours(minutes(90)), $timeout / hours(1), hours(days(1))", numbers: WithIntegers(operator.TruncatedDivision), expected: []interface{}{1.5, 1.5, int64(24)}},
+		{expressions: "hours(minutes(90)), $timeout / minutes(20)", numbers: WithRational(), expected: []interface{}{big.NewRat(3, 2), big.NewRat(9, 2)}},
+		{expressions: "date('tomorrow')", expectErr: true},
+		{expressions: "date(2026, 1)", expectErr: true},
+		{expressions: "now() + now()", expectErr: true},
+		{expressions: "now() + 1", expectErr: true},
+		{expressions: "$timeout / 0", expectErr: true},
+		{expressions: "days(1e10)", expectErr: true},
+		{expressions: "now() < days(1)", expectErr: true},
+		{expressions: "addmonths(now(), 1.5)", expectErr: true},
+	}
+
+	for _, c := range cases {
+		opts := []Option{WithClock(func() time.Time { return now })}
+		if c.numbers != nil {
+			opts = append(opts, c.numbers)
+		}
+
+		result, err := New(opts...).Eval(c.expressions, m)
+		if c.expectErr && err == nil {
+			t.Errorf("expect error, got nil, expressions: %s", c.expressions)
+		}
+
+		if !c.expectErr && err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c.expressions)
+		}
+
+		if !reflect.DeepEqual(c.expected, result) {
+			t.Errorf("expected: %v, got: %v, expressions: %s", c.expected, result, c.expressions)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	m := map[string]interface{}{
+		"distance": 42,
+		"speed":    operator.Quantity{Value: 3.0, Unit: mustParseUnit(t, "m/s")},
+	}
+
+	cases := []struct {
+		expressions string
+		numbers     Option
+		expected    string
+		kind        Kind
+	}{
+		{expressions: "3 km + 200 m", expected: "3.2 km"},
+		{expressions: "3 km + 200 m to m, 200 m + 3 km", expected: "[3200 m 3200 m]"},
+		{expressions: "60 mph to m/s", expected: "26.8224 m/s"},
+		{expressions: "5 kg * 10 m/s^2 to N", expected: "50 N"},
+		{expressions: "5 kg * 10 m/s^2", expected: "50 kg*m/s^2"},
+		{expressions: "$distance km / 2 h", expected: "21 km/h"},
+		{expressions: "$speed * 10 s, $speed in km/h", expected: "[30 m 10.8 km/h]"},
+		{expressions: "1 km / 1 m, 30 min / h, 2 / 4 s", expected: "[1000 0.5 0.5 1/s]"},
+		{expressions: "(2 m)^2, 2 m^2, 1 ha to m^2, 10 L / 1 m^2 to mm", expected: "[4 m^2 2 m^2 10000 m^2 10 mm]"},
+		{expressions: "-3 min, 2 * 3 min - 1 min", expected: "[-3 min 5 min]"},
+		{expressions: "100 degC to degF, 32 degF to degC, 0 degC in K, 20 degC + 5 degC", expected: "[212 degF 0 degC 273.15 K 25 degC]"},
+		{expressions: "1 mi > 1 km, 1 km == 1000 m, 1 ft < 1 inch, 0 degC == 273.15 K", expected: "[true true false true]"},
+		{expressions: "1 mi to km, 1 kWh to J", numbers: WithDecimal(4, decimal.HalfEven), expected: "[1.609344 km 3600000 J]"},
+		{expressions: "1 m to ft", numbers: WithDecimal(4, decimal.HalfEven), expected: "3.2808 ft"},
+		{expressions: "1 mi to km, 3 min / 2", numbers: WithRational(), expected: "[25146/15625 km 3/2 min]"},
+		{expressions: "1 km + 1 m, 7 min / 2", numbers: WithIntegers(operator.TruncatedDivision), expected: "[1.001 km 3 min]"},
+		{expressions: "1 m + 1 s", kind: KindIncompatibleUnits, expected: "m and s"},
+		{expressions: "1 m + 1", kind: KindIncompatibleUnits, expected: "m and number"},
+		{expressions: "1 m < 1 kg", kind: KindIncompatibleUnits, expected: "m and kg"},
+		{expressions: "1 m > 1 s, 1 s == 1 m", kind: KindIncompatibleUnits, expected: "m and s"},
+		{expressions: "2 > 1 s", kind: KindIncompatibleUnits, expected: "number and s"},
+		{expressions: "60 mph to s", kind: KindIncompatibleUnits, expected: "mph and s"},
+		{expressions: "20 degC + 5 K", kind: KindIncompatibleUnits},
+		{expressions: "1 degC * 1 m", kind: KindIncompatibleUnits},
+		{expressions: "1 m to 2 km", kind: KindTypeMismatch},
+		{expressions: "'a' m", kind: KindSyntax},
+	}
+
+	for _, c := range cases {
+		var opts []Option
+		if c.numbers != nil {
+			opts = append(opts, c.numbers)
+		}
+
+		result, err := New(opts...).Eval(c.expressions, m)
+		if c.kind != 0 {
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != c.kind {
+				t.Errorf("expected error kind: %v, got: %v, expressions: %s", c.kind, err, c.expressions)
+			} else if !strings.Contains(e.Error(), c.expected) {
+				t.Errorf("expected error: %s, got: %v, expressions: %s", c.expected, err, c.expressions)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c.expressions)
+			continue
+		}
+		if s := fmt.Sprint(result); s != c.expected {
+			t.Errorf("expected: %s, got: %s, expressions: %s", c.expected, s, c.expressions)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	rates, err := operator.LoadStaticRates(strings.NewReader(`{"base": "USD", "rates": {"EUR": 0.8, "GBP": 0.75, "JPY": 150}}`))
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	m := map[string]interface{}{
+		"price": operator.Money{Amount: decimal.New(1999, 2), Currency: "USD"},
+		"qty":   3,
+	}
+
+	cases := []struct {
+		expressions string
+		numbers     Option
+		expected    string
+		kind        Kind
+	}{
+		{expressions: "100 USD + 50.5 USD", expected: "150.5 USD"},
+		{expressions: "$price * $qty, $qty * $price, $price / 2", expected: "[59.97 USD 59.97 USD 9.995 USD]"},
+		{expressions: "0.1 USD + 0.2 USD == 0.3 USD, 10 USD > 9.99 USD", expected: "[true true]"},
+		{expressions: "-$price, round2(10 USD / 3)", expected: "[-19.99 USD 3.33 USD]"},
+		{expressions: "30 USD / 20 USD", expected: "1.5"},
+		{expressions: "30 USD / 20 USD", numbers: WithDecimal(4, decimal.HalfEven), expected: "1.5"},
+		{expressions: "100 USD in EUR, 100 EUR in USD, 3 EUR in GBP", expected: "[80 EUR 125 USD 2.8125 GBP]"},
+		{expressions: "100 USD + 50 EUR in GBP", expected: "121.875 GBP"},
+		{expressions: "100 USD - 40 EUR to USD, 1 USD in USD", expected: "[50 USD 1 USD]"},
+		{expressions: "round2(1 GBP in JPY, 0)", expected: "200 JPY"},
+		{expressions: "100 USD + 50 EUR", kind: KindMixedCurrencies},
+		{expressions: "100 USD < 50 EUR", kind: KindMixedCurrencies},
+		{expressions: "100 USD + 1", kind: KindTypeMismatch},
+		{expressions: "100 USD * 2 USD", kind: KindTypeMismatch},
+		{expressions: "100 USD / 0", kind: KindDivisionByZero},
+		{expressions: "1 USD in CHF", kind: KindRuntime},
+		{expressions: "1 USD in 2 EUR", kind: KindTypeMismatch},
+	}
+
+	for _, c := range cases {
+		opts := []Option{WithRateProvider(rates)}
+		if c.numbers != nil {
+			opts = append(opts, c.numbers)
+		}
+
+		result, err := New(opts...).Eval(c.expressions, m)
+		if c.kind != 0 {
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != c.kind {
+				t.Errorf("expected error kind: %v, got: %v, expressions: %s", c.kind, err, c.expressions)
+			} else if !strings.Contains(e.Error(), c.expected) {
+				t.Errorf("expected error: %s, got: %v, expressions: %s", c.expected, err, c.expressions)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c.expressions)
+			continue
+		}
+		if s := fmt.Sprint(result); s != c.expected {
+			t.Errorf("expected: %s, got: %s, expressions: %s", c.expected, s, c.expressions)
+		}
+	}
+
+	_, err = Eval("1 USD + 1 EUR", nil)
+	var ce *operator.CurrencyError
+	if !errors.As(err, &ce) || ce.Op != operator.ADD || ce.X != "USD" || ce.Y != "EUR" {
+		t.Errorf("expected currency error, got %v", err)
+	}
+	if !errors.Is(err, ErrMixedCurrencies) {
+		t.Errorf("expected mixed currencies error, got %v", err)
+	}
+
+	if _, err := Eval("1 USD in EUR", nil); !errors.Is(err, operator.ErrNoRateProvider) {
+		t.Errorf("expected no rate provider error, got %v", err)
+	}
+}
+
+func mustParseUnit(t *testing.T, s string) operator.Unit {
+	u, err := operator.ParseUnit(s)
+	if err != nil {
+		t.Fatalf("expect no error, got %v, unit: %s", err, s)
+	}
+
+	return u
+}
+
+func TestBitwise(t *testing.T) {
+	cases := []struct {
+		expressions string
+		options     []Option
+		expected    string
+		kind        Kind
+	}{
+		{expressions: "0xFF, 0b1010, 0o17, 017, 0x_FF_FF", expected: "[255 10 15 17 65535]"},
+		{expressions: "0xFF & 0b1010, 0xF0 | 0x0F, 1 << 4, 0x100 >> 4, -16 >> 2, ~5", expected: "[10 255 16 16 -4 -6]"},
+		{expressions: "1 << 2 + 1, (6 & 3) == 2, 1 | 6 & 3", expected: "[8 true 3]"},
+		{expressions: "2 ^ 3, 0b11 ^ 2", expected: "[8 9]"},
+		{expressions: "0b1100 ^ 0b1010, 1 | 2 ^ 3 & 6, 2 ** 3", options: []Option{WithCaretXor()}, expected: "[6 1 8]"},
+		{expressions: "0xFFFFFFFFFFFFFFFF + 1, 1 << 64", options: []Option{WithIntegers(operator.FloatDivision)}, expected: "[18446744073709551616 18446744073709551616]"},
+		{expressions: "0xFF & 0x0F, 0b11 << 2", options: []Option{WithDecimal(4, decimal.HalfEven)}, expected: "[15 12]"},
+		{expressions: "~0x0F, 0x10 / 4", options: []Option{WithRational()}, expected: "[-16 4/1]"},
+		{expressions: "0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF & 1, 0xFFFFFFFFFFFFFFFF >> 60", expected: "[18446744073709551615 1 15]"},
+		{expressions: "(0xFFFFFFFFFFFFFFFF & 0xFFFF0000FFFF0000) == 0xFFFF0000FFFF0000", expected: "true"},
+		{expressions: "2^53 | 1, 0xFF * 0.5", expected: "[9007199254740993 127.5]"},
+		{expressions: "2^54 & 1", kind: KindTypeMismatch},
+		{expressions: "1.5 & 1", kind: KindTypeMismatch},
+		{expressions: "1 << -1", kind: KindTypeMismatch},
+		{expressions: "1 << 100000", kind: KindTypeMismatch},
+		{expressions: "~true", kind: KindTypeMismatch},
+		{expressions: "0b102", kind: KindSyntax},
+	}
+
+	for _, c := range cases {
+		result, err := New(c.options...).Eval(c.expressions, nil)
+		if c.kind != 0 {
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != c.kind {
+				t.Errorf("expected error kind: %v, got: %v, expressions: %s", c.kind, err, c.expressions)
+			} else if !strings.Contains(e.Error(), c.expected) {
+				t.Errorf("expected error: %s, got: %v, expressions: %s", c.expected, err, c.expressions)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, expressions: %s", err, c.expressions)
+			continue
+		}
+		if s := fmt.Sprint(result); s != c.expected {
+			t.Errorf("expected: %s, got: %s, expressions: %s", c.expected, s, c.expressions)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	cases := []struct {
+		expressions string
+		m           map[string]interface{}
+		kind        Kind
+		sentinel    error
+		line        int
+		column      int
+		token       string
+	}{
+		{expressions: "1+\n  2/$b", m: map[string]interface{}{"b": 0}, kind: KindDivisionByZero, sentinel: ErrDivisionByZero, line: 2, column: 4, token: "/"},
+		{expressions: "1 + $abc", kind: KindUnknownVariable, sentinel: ErrUnknownVariable, line: 1, column: 5, token: "$abc"},
+		{expressions: "1 + 'a'", kind: KindTypeMismatch, sentinel: ErrTypeMismatch, line: 1, column: 3, token: "+"},
+		{expressions: "2 * x", kind: KindSyntax, sentinel: ErrSyntax, line: 1, column: 5, token: "x"},
+		{expressions: "1+pow(1)*sin(1,2)", kind: KindArity, sentinel: ErrArity, line: 1, column: 10, token: "sin"},
+		{expressions: "$a ? 1 : 2", m: map[string]interface{}{"a": 1}, kind: KindTypeMismatch, sentinel: ErrTypeMismatch, line: 1, column: 4, token: "?"},
+		{expressions: "1 + ln(0)", kind: KindNotFinite, sentinel: ErrNotFinite, line: 1, column: 5, token: "ln"},
+		{expressions: "2 * 1e308^2", kind: KindNotFinite, sentinel: ErrNotFinite, line: 1, column: 10, token: "^"},
+		{expressions: "2 * 0^-1", kind: KindDivisionByZero, sentinel: ErrDivisionByZero, line: 1, column: 6, token: "^"},
+		{expressions: "0**-1 + pow(0, -1)", kind: KindDivisionByZero, sentinel: ErrDivisionByZero, line: 1, column: 2, token: "**"},
+		{expressions: "pow(0, -1)", kind: KindDivisionByZero, sentinel: ErrDivisionByZero, line: 1, column: 1, token: "pow"},
+		{expressions: "1e308 m * 10", kind: KindNotFinite, sentinel: ErrNotFinite, line: 1, column: 9, token: "*"},
+		{expressions: "1 km + 2 s", kind: KindIncompatibleUnits, sentinel: ErrIncompatibleUnits, line: 1, column: 6, token: "+"},
+		{expressions: "1 USD + 2 EUR", kind: KindMixedCurrencies, sentinel: ErrMixedCurrencies, line: 1, column: 7, token: "+"},
+		{expressions: "matches($a, '[a')", kind: KindSyntax, sentinel: ErrSyntax, line: 1, column: 13, token: `"[a"`},
+		{expressions: "$a =~ 'b' || $a =~ 'a)'", kind: KindSyntax, sentinel: ErrSyntax, line: 1, column: 20, token: `"a)"`},
+		{expressions: "matches('a', $a)", m: map[string]interface{}{"a": "(a"}, kind: KindTypeMismatch, sentinel: ErrTypeMismatch, line: 1, column: 1, token: "matches"},
+	}
+
+	for _, c := range cases {
+		_, err := Eval(c.expressions, c.m)
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("expect *Error, got %v, expressions: %s", err, c.expressions)
+			continue
+		}
+
+		if e.Kind != c.kind || e.Pos.Line != c.line || e.Pos.Column != c.column || e.Token != c.token {
+			t.Errorf("expected: %v %d:%d %s, got: %v %d:%d %s, expressions: %s",
+				c.kind, c.line, c.column, c.token, e.Kind, e.Pos.Line, e.Pos.Column, e.Token, c.expressions)
+		}
+
+		if !errors.Is(err, c.sentinel) {
+			t.Errorf("expect errors.Is %v, got %v, expressions: %s", c.sentinel, err, c.expressions)
+		}
+	}
+
+	_, err := Eval("1/0", nil)
+	if !errors.Is(err, operator.ErrDivisionByZero) {
+		t.Errorf("expect wrapping the operator error, got %v", err)
+	}
+
+	messages := map[string]string{
+		"1/0":           "calc: 1:2: division by zero",
+		"1 km + 2 s":    "calc: 1:6: incompatible units: km and s",
+		"1 USD + 2 EUR": "calc: 1:7: mixed currencies for code: +, USD and EUR, convert them by `in`",
+		"abs('a')":      "calc: 1:1: invalid arguments for code: abs",
+		"1.5 & 1":       "calc: 1:5: invalid arguments for code: &",
+		"1 << -1":       "calc: 1:3: invalid arguments for code: <<",
+		"~1.5":          "calc: 1:1: invalid arguments for code: ~",
+	}
+	for expressions, expected := range messages {
+		if _, err := Eval(expressions, nil); err == nil || err.Error() != expected {
+			t.Errorf("expected: %s, got: %v, expressions: %s", expected, err, expressions)
+		}
+	}
+	// zero to a negative power is division by zero in all number modes
+	for _, c := range []*Calculator{New(), New(WithDecimal(4, decimal.HalfEven)), New(WithRational()), New(WithIntegers(operator.FloatDivision))} {
+		for _, expressions := range []string{"0^-1", "0**-2", "pow(0, -1)", "0^-0.5"} {
+			if _, err := c.Eval(expressions, nil); !errors.Is(err, ErrDivisionByZero) {
+				t.Errorf("expect division by zero, got %v, expressions: %s", err, expressions)
+			}
+		}
+	}
+
+	// the not finite results are the same in all number modes
+	for _, opt := range []Option{WithDecimal(4, decimal.HalfEven), WithRational(), WithIntegers(operator.FloatDivision)} {
+		c := New(opt)
+		for _, expressions := range []string{"ln(0)", "ln(-1)", "asin(2)", "exp(1000)", "nper(0.1, 0, 100)"} {
+			_, err := c.Eval(expressions, nil)
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindNotFinite || !errors.Is(err, operator.ErrNotFinite) {
+				t.Errorf("expect not finite error, got %v, expressions: %s", err, expressions)
+			}
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	cases := []struct {
+		expressions string
+		column      int
+		msg         string
+	}{
+		{expressions: "(1+2", column: 1, msg: "missing ')' for '('"},
+		{expressions: "max(1,(2+3)", column: 4, msg: "missing ')' for '('"},
+		{expressions: "1 ? 2", column: 3, msg: "missing ':' for '?'"},
+		{expressions: "1+2)", column: 4, msg: "unmatched ')'"},
+		{expressions: "1+", column: 2, msg: "missing operand after '+'"},
+		{expressions: "1*(2-)", column: 5, msg: "missing operand after '-'"},
+		{expressions: "max(1,)", column: 6, msg: "missing operand after ','"},
+		{expressions: "1+*2", column: 3, msg: "missing operand before '*'"},
+		{expressions: "1 2", column: 3, msg: "missing operator between '1' and '2'"},
+		{expressions: "$a $b", column: 4, msg: "missing operator between 'a' and '$'"},
+		{expressions: "sin()", column: 1, msg: "empty call of function 'sin'"},
+		{expressions: "1+()", column: 4, msg: "empty parentheses"},
+		{expressions: "1+foo(2)", column: 3, msg: "unknown function: 'foo'"},
+		{expressions: "1+a", column: 3, msg: "unknown identifier: 'a'"},
+		{expressions: `"abc`, column: 1, msg: "literal not terminated"},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c.expressions)
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("expect *Error, got %v, expressions: %s", err, c.expressions)
+			continue
+		}
+
+		if e.Pos.Column != c.column || !strings.Contains(e.Msg, c.msg) {
+			t.Errorf("expected: %d %s, got: %d %s, expressions: %s", c.column, c.msg, e.Pos.Column, e.Msg, c.expressions)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xwjdsh/calc/decimal"
+	"github.com/xwjdsh/calc/operator"
+)
+
+// formats are the output formats of the results, auto is fixed or scientific notation by the magnitude.
+var formats = []string{"auto", "fixed", "sci", "eng", "hex", "bin", "oct", "json"}
+
+// bases are the integer formats, the prefixes are the same as the literals, e.g. `0xff`.
+var bases = map[string]struct {
+	prefix string
+	base   int
+}{
+	"hex": {"0x", 16},
+	"bin": {"0b", 2},
+	"oct": {"0o", 8},
+}
+
+func isFormat(f string) bool {
+	for _, s := range formats {
+		if s == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatter formats the results for the output.
+type formatter struct {
+	// format is one of formats
+	format string
+	// precision is the digits count after the decimal point, it is the shortest exact representation if negative
+	precision int
+	// thousands groups the integer digits by `,`, e.g. `1,234,567.5`
+	thousands bool
+}
+
+// text returns the result in the format except json, the items of list are separated by `, `,
+// or `; ` if the digits are grouped by `,`, e.g. `1,000; 2,000`.
+func (f *formatter) text(v interface{}) (string, error) {
+	switch n := v.(type) {
+	case []interface{}:
+		items := make([]string, len(n))
+		for i, item := range n {
+			s, err := f.text(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		sep := ", "
+		if f.thousands {
+			sep = "; "
+		}
+		return strings.Join(items, sep), nil
+	case operator.Quantity:
+		s, err := f.text(n.Value)
+		return s + " " + n.Unit.String(), err
+	case operator.Money:
+		s, err := f.text(n.Amount)
+		return s + " " + n.Currency, err
+	}
+
+	if b, ok := bases[f.format]; ok {
+		i, ok := integer(v)
+		if !ok {
+			return "", fmt.Errorf("calc: result %v is not an integer, could not be formatted as %s", v, f.format)
+		}
+		if i.Sign() < 0 {
+			return "-" + b.prefix + new(big.Int).Neg(i).Text(b.base), nil
+		}
+		return b.prefix + i.Text(b.base), nil
+	}
+
+	d, ok := toDecimal(v, f.precision)
+	if !ok {
+		return fmt.Sprint(v), nil
+	}
+
+	var s string
+	switch f.format {
+	case "fixed":
+		s = fixed(d, f.precision)
+	case "sci":
+		s = scientific(d, f.precision, 1)
+	case "eng":
+		s = scientific(d, f.precision, 3)
+	default:
+		s = auto(v, d, f.precision)
+	}
+
+	if f.thousands {
+		s = groupThousands(s)
+	}
+	return s, nil
+}
+
+// auto returns number v in fixed notation, or scientific notation if it is too large or small like JavaScript,
+// e.g. `1e+21` and `1e-7`, the digits after the decimal point are rounded to the precision without trailing zeros.
+// The rational number is the fraction if the precision is negative, e.g. `1/3`.
+func auto(v interface{}, d decimal.Decimal, precision int) string {
+	if _, ok := v.(*big.Rat); ok && precision < 0 {
+		return fmt.Sprint(v)
+	}
+
+	if precision >= 0 {
+		d = d.Round(int32(precision), decimal.HalfEven).Trim(0)
+	}
+	if e := exponent(d); d.Sign() != 0 && (e >= 21 || e < -6) {
+		return scientific(d, -1, 1)
+	}
+	return d.String()
+}
+
+// json returns the JSON value of result, the numbers are exact, e.g. `0.1` of decimal is `0.1` rather than the float64,
+// the quantity and money are objects, e.g. `{"amount": 1.5, "currency": "USD"}`.
+func (f *formatter) json(v interface{}) interface{} {
+	switch n := v.(type) {
+	case []interface{}:
+		items := make([]interface{}, len(n))
+		for i, item := range n {
+			items[i] = f.json(item)
+		}
+		return items
+	case operator.Quantity:
+		return map[string]interface{}{"value": f.json(n.Value), "unit": n.Unit.String()}
+	case operator.Money:
+		return map[string]interface{}{"amount": f.json(n.Amount), "currency": n.Currency}
+	case time.Duration, complex128:
+		return fmt.Sprint(n)
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return fmt.Sprint(n)
+		}
+		if f.precision < 0 {
+			return json.Number(strconv.FormatFloat(n, 'g', -1, 64))
+		}
+	}
+
+	d, ok := toDecimal(v, f.precision)
+	if !ok {
+		return v
+	}
+	if f.precision >= 0 {
+		d = d.Round(int32(f.precision), decimal.HalfEven).Trim(0)
+	}
+	return json.Number(d.String())
+}
+
+// typeOf returns the type name of result in the JSON envelope.
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case int64, *big.Int:
+		return "integer"
+	case float64:
+		return "float"
+	case decimal.Decimal:
+		return "decimal"
+	case *big.Rat:
+		return "rational"
+	case complex128:
+		return "complex"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "list"
+	case time.Time:
+		return "time"
+	case time.Duration:
+		return "duration"
+	case operator.Quantity:
+		return "quantity"
+	case operator.Money:
+		return "money"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+// integer returns the value of number v if it is an integer.
+func integer(v interface{}) (*big.Int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return big.NewInt(n), true
+	case *big.Int:
+		return n, true
+	case float64:
+		if !math.IsInf(n, 0) && n == math.Trunc(n) {
+			i, _ := big.NewFloat(n).Int(nil)
+			return i, true
+		}
+	case decimal.Decimal:
+		if n.IsInteger() {
+			return n.Int(), true
+		}
+	case *big.Rat:
+		if n.IsInt() {
+			return n.Num(), true
+		}
+	}
+
+	return nil, false
+}
+
+// toDecimal converts the real number v to decimal, the float64 is its shortest representation,
+// and the rational number is rounded to the precision and operator.DefaultDecimalPrecision more digits.
+func toDecimal(v interface{}, precision int) (decimal.Decimal, bool) {
+	switch n := v.(type) {
+	case int64:
+		return decimal.New(n, 0), true
+	case *big.Int:
+		return decimal.NewFromBigInt(n, 0), true
+	case float64:
+		d, err := decimal.NewFromFloat(n)
+		return d, err == nil
+	case decimal.Decimal:
+		return n, true
+	case *big.Rat:
+		if precision < 0 {
+			precision = 0
+		}
+		d, _ := decimal.NewFromBigInt(n.Num(), 0).Quo(decimal.NewFromBigInt(n.Denom(), 0), int32(precision)+operator.DefaultDecimalPrecision, decimal.HalfEven)
+		return d, true
+	}
+
+	return decimal.Decimal{}, false
+}
+
+// fixed returns d without exponent, e.g. `1000000000000000000000` rather than `1e+21`,
+// it has exactly precision digits after the decimal point if precision is not negative.
+func fixed(d decimal.Decimal, precision int) string {
+	if precision < 0 {
+		return d.String()
+	}
+
+	return padFraction(d.Round(int32(precision), decimal.HalfEven).String(), precision)
+}
+
+// scientific returns d in scientific notation, e.g. `1.5e+21`, the exponent is a multiple of step,
+// it is 3 for engineering notation, e.g. `12.5e+3`.
+func scientific(d decimal.Decimal, precision, step int) string {
+	sign := ""
+	if d.Sign() < 0 {
+		sign, d = "-", d.Neg()
+	}
+
+	e := 0
+	if d.Sign() != 0 {
+		e = floorStep(exponent(d), step)
+		if precision >= 0 {
+			// the rounding may carry to the next exponent, e.g. 9.99 is 10.0 for precision 1
+			d = d.Round(int32(precision-e), decimal.HalfEven)
+			e = floorStep(exponent(d), step)
+		}
+	}
+
+	// mantissa is d * 10^-e
+	m := d.Mul(decimal.New(1, int32(e)))
+	s := m.Trim(0).String()
+	if precision >= 0 {
+		s = padFraction(m.Round(int32(precision), decimal.HalfEven).String(), precision)
+	}
+
+	return sign + s + "e" + fmt.Sprintf("%+d", e)
+}
+
+// exponent returns the exponent of the most significant digit of non-zero d, e.g. 2 for 123.4.
+func exponent(d decimal.Decimal) int {
+	unscaled := d.Mul(decimal.New(1, -d.Scale())).Int()
+	return len(new(big.Int).Abs(unscaled).String()) - 1 - int(d.Scale())
+}
+
+// floorStep returns the greatest multiple of step not greater than e.
+func floorStep(e, step int) int {
+	return e - ((e%step)+step)%step
+}
+
+// padFraction appends zeros to the number s until it has precision digits after the decimal point.
+func padFraction(s string, precision int) string {
+	if precision <= 0 {
+		return s
+	}
+
+	i := strings.IndexByte(s, '.')
+	if i < 0 {
+		s += "."
+		i = len(s) - 1
+	}
+	return s + strings.Repeat("0", precision-(len(s)-i-1))
+}
+
+// groupThousands separates the integer digits of number s by `,`, e.g. `-1234.5` is `-1,234.5`,
+// s is not changed if it is not a decimal number, e.g. the rational number `1234/5`.
+func groupThousands(s string) string {
+	start := 0
+	if strings.HasPrefix(s, "-") {
+		start = 1
+	}
+	end := start
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	if end-start <= 3 || (end < len(s) && s[end] != '.' && s[end] != 'e') {
+		return s
+	}
+
+	digits := s[start:end]
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+
+	return s[:start] + b.String() + s[end:]
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xwjdsh/calc/decimal"
+	"github.com/xwjdsh/calc/operator"
+)
+
+func TestOutput(t *testing.T) {
+	amount, _ := decimal.Parse("1234.5")
+	cases := []struct {
+		result    interface{}
+		f         formatter
+		envelope  bool
+		expected  string
+		expectErr bool
+	}{
+		{result: 1234567.891, f: formatter{format: "auto", precision: -1}, expected: "1234567.891"},
+		{result: 1234567.891, f: formatter{format: "fixed", precision: 2, thousands: true}, expected: "1,234,567.89"},
+		{result: 1234567.891, f: formatter{format: "sci", precision: 2}, expected: "1.23e+6"},
+		{result: -0.125, f: formatter{format: "eng", precision: -1}, expected: "-125e-3"},
+		{result: int64(-255), f: formatter{format: "hex", precision: -1}, expected: "-0xff"},
+		{result: int64(255), f: formatter{format: "bin", precision: 2}, expected: "0b11111111"},
+		{result: new(big.Int).Lsh(big.NewInt(1), 70), f: formatter{format: "oct", precision: -1}, expected: "0o200000000000000000000000"},
+		{result: big.NewRat(1, 3), f: formatter{format: "auto", precision: -1}, expected: "1/3"},
+		{result: []interface{}{1e21, 2e-7}, f: formatter{format: "auto", precision: -1}, expected: "1e+21, 2e-7"},
+		{result: []interface{}{1e6, 2.5}, f: formatter{format: "fixed", precision: 2, thousands: true}, expected: "1,000,000.00; 2.50"},
+		{result: operator.Money{Amount: amount, Currency: "USD"}, f: formatter{format: "fixed", precision: 2, thousands: true}, expected: "1,234.50 USD"},
+		{result: 1.5, f: formatter{format: "hex", precision: -1}, expectErr: true},
+
+		{result: big.NewRat(1, 3), f: formatter{format: "json", precision: 2}, expected: "0.33"},
+		{result: 1234567.891, f: formatter{format: "auto", precision: 2}, envelope: true, expected: `{"result":1234567.89,"type":"float"}`},
+		{result: int64(255), f: formatter{format: "hex", precision: -1}, envelope: true, expected: `{"result":"0xff","type":"string"}`},
+		{result: 1234567.891, f: formatter{format: "fixed", precision: -1, thousands: true}, envelope: true, expected: `{"result":"1,234,567.891","type":"string"}`},
+		{result: []interface{}{int64(1), 2.5}, f: formatter{format: "json", precision: -1}, envelope: true, expected: `{"result":[1,2.5],"type":"list"}`},
+		{result: operator.Money{Amount: amount, Currency: "USD"}, f: formatter{format: "auto", precision: -1}, envelope: true, expected: `{"result":{"amount":1234.5,"currency":"USD"},"type":"money"}`},
+		{result: 90 * time.Minute, f: formatter{format: "json", precision: -1}, envelope: true, expected: `{"result":"1h30m0s","type":"duration"}`},
+	}
+
+	for _, c := range cases {
+		var b bytes.Buffer
+		f := c.f
+		err := output(&b, &f, c.envelope, c.result)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("expect error, got nil, result: %v, format: %s", c.result, c.f.format)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, result: %v, format: %s", err, c.result, c.f.format)
+			continue
+		}
+
+		if s := strings.TrimSuffix(b.String(), "\n"); s != c.expected {
+			t.Errorf("expected: %s, got: %s, result: %v, format: %s", c.expected, s, c.result, c.f.format)
+		}
+	}
+}
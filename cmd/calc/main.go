@@ -2,19 +2,31 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/xwjdsh/calc"
+	"github.com/xwjdsh/calc/operator"
 )
 
 // example: go run main.go -m '{"a":1}' 'sum($a,2,3)+2*3'
 
 func main() {
-	var mapJSON string
+	var mapJSON, angle, rates string
+	var caretXor, envelope bool
+	f := &formatter{}
 	flag.StringVar(&mapJSON, "m", "", "variable map, JSON format")
+	flag.StringVar(&angle, "angle", "rad", "angle unit of trigonometric functions, rad, deg or grad")
+	flag.StringVar(&rates, "rates", "", `exchange rates file, JSON format, example: {"base": "USD", "rates": {"EUR": 0.92}}`)
+	flag.BoolVar(&caretXor, "xor", false, "'^' is bitwise exclusive or instead of exponent")
+	flag.StringVar(&f.format, "format", "auto", "output format, "+strings.Join(formats, ", "))
+	flag.IntVar(&f.precision, "precision", -1, "digits after the decimal point, -1 is the shortest exact representation")
+	flag.BoolVar(&f.thousands, "thousands", false, "separate the integer digits by ',', e.g. 1,234,567")
+	flag.BoolVar(&envelope, "json", false, `print the JSON object {"result": ..., "type": ...}, or {"error": ..., "kind": ...}`)
 	flag.Parse()
 
 	values := flag.Args()
@@ -33,11 +45,98 @@ func main() {
 		}
 	}
 
-	result, err := calc.Eval(strings.Join(values, ""), m)
+	unit, err := operator.ParseAngleUnit(angle)
 	if err != nil {
-		fmt.Println(err.Error())
+		fmt.Println("Usage: -angle flag require rad, deg or grad")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if !isFormat(f.format) {
+		fmt.Printf("Usage: -format flag require %s\n", strings.Join(formats, ", "))
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	opts := []calc.Option{calc.WithAngleUnit(unit)}
+	if caretXor {
+		opts = append(opts, calc.WithCaretXor())
+	}
+	if rates != "" {
+		provider, err := loadRates(rates)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, calc.WithRateProvider(provider))
+	}
+
+	c := calc.New(opts...)
+	result, err := c.Eval(strings.Join(values, ""), m)
+	if err == nil {
+		err = output(os.Stdout, f, envelope, result)
+	}
+	if err != nil {
+		if envelope {
+			printJSON(os.Stdout, errorEnvelope(err))
+		} else {
+			fmt.Println(err.Error())
+		}
 		os.Exit(1)
 	}
+}
+
+// output writes the result in the format to w, the result of JSON envelope is the JSON value in auto and json formats,
+// or the text in the others, the type is the one of the written value, e.g. `{"result": "0xff", "type": "string"}`.
+func output(w io.Writer, f *formatter, envelope bool, result interface{}) error {
+	var v interface{}
+	typ := typeOf(result)
+	if f.format == "json" || (envelope && f.format == "auto") {
+		v = f.json(result)
+	} else {
+		s, err := f.text(result)
+		if err != nil {
+			return err
+		}
+		if !envelope {
+			_, err = fmt.Fprintln(w, s)
+			return err
+		}
+		v, typ = s, typeOf(s)
+	}
+
+	if envelope {
+		v = map[string]interface{}{"result": v, "type": typ}
+	}
+	return printJSON(w, v)
+}
+
+func errorEnvelope(err error) map[string]interface{} {
+	m := map[string]interface{}{"error": err.Error()}
+	var e *calc.Error
+	if errors.As(err, &e) {
+		m["kind"] = e.Kind.String()
+	}
+
+	return m
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func loadRates(name string) (*operator.StaticRates, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	fmt.Println(result)
+	return operator.LoadStaticRates(f)
 }
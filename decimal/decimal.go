@@ -0,0 +1,373 @@
+// Package decimal implements the arbitrary-precision decimal number, e.g. `0.1+0.2` is exactly `0.3`.
+
+package decimal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode determines how a decimal is rounded.
+type RoundingMode int
+
+const (
+	// HalfEven rounds to nearest, ties to even, also known as banker's rounding.
+	HalfEven RoundingMode = iota
+	// HalfUp rounds to nearest, ties away from zero.
+	HalfUp
+	// HalfDown rounds to nearest, ties toward zero.
+	HalfDown
+	// Down rounds toward zero.
+	Down
+	// Up rounds away from zero.
+	Up
+	// Floor rounds toward negative infinity.
+	Floor
+	// Ceiling rounds toward positive infinity.
+	Ceiling
+)
+
+var roundingModeNames = []string{"half_even", "half_up", "half_down", "down", "up", "floor", "ceiling"}
+
+func (m RoundingMode) String() string {
+	if m >= 0 && int(m) < len(roundingModeNames) {
+		return roundingModeNames[m]
+	}
+
+	return fmt.Sprintf("RoundingMode(%d)", int(m))
+}
+
+// ParseRoundingMode returns the rounding mode by name, e.g. `half_even`.
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	for i, name := range roundingModeNames {
+		if strings.EqualFold(s, name) {
+			return RoundingMode(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("decimal: unknown rounding mode: %s", s)
+}
+
+var (
+	// ErrDivisionByZero is returned when the divisor is zero.
+	ErrDivisionByZero = errors.New("decimal: division by zero")
+	// ErrOverflow is returned when the number is too large to be exact, e.g. `1e2000000000`.
+	ErrOverflow = errors.New("decimal: number overflow")
+)
+
+const (
+	// maxExponent is the maximum magnitude of the exponent of parsed numbers.
+	maxExponent = 1 << 16
+	// maxPowBits is the maximum bits count of the unscaled result of Pow.
+	maxPowBits = 1 << 16
+)
+
+// Decimal represents value * 10^(-scale), the zero value is 0.
+// It is immutable, all operations return new values.
+type Decimal struct {
+	value *big.Int
+	scale int32
+}
+
+// New returns value * 10^(-scale).
+func New(value int64, scale int32) Decimal {
+	return Decimal{value: big.NewInt(value), scale: scale}
+}
+
+// NewFromBigInt returns value * 10^(-scale).
+func NewFromBigInt(value *big.Int, scale int32) Decimal {
+	return Decimal{value: new(big.Int).Set(value), scale: scale}
+}
+
+// NewFromFloat returns the decimal of the shortest representation of f, e.g. `0.1` for 0.1.
+func NewFromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, fmt.Errorf("decimal: invalid float: %v", f)
+	}
+
+	return Parse(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// Parse parses the decimal string, e.g. `-1.25` or `1.5e-3`.
+func Parse(s string) (Decimal, error) {
+	invalid := fmt.Errorf("decimal: invalid number: %s", s)
+
+	str, exp := s, int64(0)
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(str[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, invalid
+		}
+		str, exp = str[:i], e
+	}
+
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	if strings.ContainsAny(fracPart, "+-") {
+		return Decimal{}, invalid
+	}
+
+	value, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return Decimal{}, invalid
+	}
+
+	scale := int64(len(fracPart)) - exp
+	if scale > maxExponent || scale < -maxExponent {
+		return Decimal{}, fmt.Errorf("%w: %s", ErrOverflow, s)
+	}
+	if scale < 0 {
+		value.Mul(value, pow10(-scale))
+		scale = 0
+	}
+
+	return Decimal{value: value, scale: int32(scale)}, nil
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+}
+
+func (d Decimal) unscaled() *big.Int {
+	if d.value == nil {
+		return new(big.Int)
+	}
+
+	return d.value
+}
+
+// rescale returns the unscaled value with the bigger scale.
+func (d Decimal) rescale(scale int32) *big.Int {
+	v := d.unscaled()
+	if scale <= d.scale {
+		return new(big.Int).Set(v)
+	}
+
+	return new(big.Int).Mul(v, pow10(int64(scale-d.scale)))
+}
+
+// align returns the unscaled values of d and d2 with the same scale.
+func align(d, d2 Decimal) (*big.Int, *big.Int, int32) {
+	scale := d.scale
+	if d2.scale > scale {
+		scale = d2.scale
+	}
+
+	return d.rescale(scale), d2.rescale(scale), scale
+}
+
+// Add returns d + d2.
+func (d Decimal) Add(d2 Decimal) Decimal {
+	v1, v2, scale := align(d, d2)
+	return Decimal{value: v1.Add(v1, v2), scale: scale}
+}
+
+// Sub returns d - d2.
+func (d Decimal) Sub(d2 Decimal) Decimal {
+	v1, v2, scale := align(d, d2)
+	return Decimal{value: v1.Sub(v1, v2), scale: scale}
+}
+
+// Mul returns d * d2.
+func (d Decimal) Mul(d2 Decimal) Decimal {
+	return Decimal{value: new(big.Int).Mul(d.unscaled(), d2.unscaled()), scale: d.scale + d2.scale}
+}
+
+// Quo returns d / d2 rounded to scale digits after the decimal point, the trailing zeros are removed.
+func (d Decimal) Quo(d2 Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if d2.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+
+	// d / d2 = (v1 * 10^-s1) / (v2 * 10^-s2) = (v1 * 10^(scale+s2-s1) / v2) * 10^-scale
+	num, den := new(big.Int).Set(d.unscaled()), new(big.Int).Set(d2.unscaled())
+	if shift := int64(scale) + int64(d2.scale) - int64(d.scale); shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+
+	return Decimal{value: roundQuo(num, den, mode), scale: scale}.Trim(d.scale), nil
+}
+
+// Rem returns the remainder of d / d2, the result has the sign of d.
+func (d Decimal) Rem(d2 Decimal) (Decimal, error) {
+	if d2.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+
+	v1, v2, scale := align(d, d2)
+	return Decimal{value: v1.Rem(v1, v2), scale: scale}, nil
+}
+
+// Pow returns d^n, the negative exponent is computed by Quo with scale and mode.
+// It is ErrOverflow if the exact result is too large, e.g. `9^(9^9)`.
+func (d Decimal) Pow(n int64, scale int32, mode RoundingMode) (Decimal, error) {
+	if n < 0 {
+		if n == math.MinInt64 {
+			return Decimal{}, fmt.Errorf("%w: exponent %d", ErrOverflow, n)
+		}
+		p, err := d.Pow(-n, scale, mode)
+		if err != nil {
+			return Decimal{}, err
+		}
+		return New(1, 0).Quo(p, scale, mode)
+	}
+
+	// the products are compared by division, since they may overflow int64
+	bits, s := int64(d.unscaled().BitLen()), int64(d.scale)
+	if (bits > 1 && n > maxPowBits/bits) || (s > 0 && n > maxExponent/s) {
+		return Decimal{}, fmt.Errorf("%w: exponent %d", ErrOverflow, n)
+	}
+
+	v := new(big.Int).Exp(d.unscaled(), big.NewInt(n), nil)
+	return Decimal{value: v, scale: d.scale * int32(n)}, nil
+}
+
+// Round returns d rounded to scale digits after the decimal point.
+func (d Decimal) Round(scale int32, mode RoundingMode) Decimal {
+	if scale >= d.scale {
+		return d
+	}
+
+	den := pow10(int64(d.scale - scale))
+	return Decimal{value: roundQuo(new(big.Int).Set(d.unscaled()), den, mode), scale: scale}
+}
+
+// roundQuo returns num / den rounded to integer by mode.
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	sign := int64(num.Sign() * den.Sign())
+	// compare the remainder with half of den
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	c := half.Cmp(new(big.Int).Abs(den))
+
+	var away bool
+	switch mode {
+	case HalfEven:
+		away = c > 0 || (c == 0 && q.Bit(0) == 1)
+	case HalfUp:
+		away = c >= 0
+	case HalfDown:
+		away = c > 0
+	case Down:
+		away = false
+	case Up:
+		away = true
+	case Floor:
+		away = sign < 0
+	case Ceiling:
+		away = sign > 0
+	}
+
+	if away {
+		q.Add(q, big.NewInt(sign))
+	}
+	return q
+}
+
+// Trim removes the trailing zeros after the decimal point until the scale is min, e.g. 1.500 is 1.5 for min 0.
+func (d Decimal) Trim(min int32) Decimal {
+	if min < 0 {
+		min = 0
+	}
+
+	v := new(big.Int).Set(d.unscaled())
+	scale := d.scale
+	ten, r := big.NewInt(10), new(big.Int)
+	for scale > min {
+		q, _ := new(big.Int).QuoRem(v, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		v, scale = q, scale-1
+	}
+
+	return Decimal{value: v, scale: scale}
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: new(big.Int).Neg(d.unscaled()), scale: d.scale}
+}
+
+// Abs returns |d|.
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: new(big.Int).Abs(d.unscaled()), scale: d.scale}
+}
+
+// Sign returns -1, 0 or 1 when d is negative, zero or positive.
+func (d Decimal) Sign() int {
+	return d.unscaled().Sign()
+}
+
+// Cmp returns -1, 0 or 1 when d is less than, equal to or greater than d2.
+func (d Decimal) Cmp(d2 Decimal) int {
+	v1, v2, _ := align(d, d2)
+	return v1.Cmp(v2)
+}
+
+// Scale returns the digits count after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// IsInteger reports whether d has no fractional part.
+func (d Decimal) IsInteger() bool {
+	return d.scale <= 0 || d.Trim(0).scale == 0
+}
+
+// Int returns the integer part of d.
+func (d Decimal) Int() *big.Int {
+	if d.scale <= 0 {
+		return d.rescale(0)
+	}
+
+	return new(big.Int).Quo(d.unscaled(), pow10(int64(d.scale)))
+}
+
+// Rat returns d as a rational number.
+func (d Decimal) Rat() *big.Rat {
+	if d.scale <= 0 {
+		return new(big.Rat).SetInt(d.rescale(0))
+	}
+
+	return new(big.Rat).SetFrac(d.unscaled(), pow10(int64(d.scale)))
+}
+
+// Float64 returns the nearest float64 of d.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String returns d in plain notation with all the digits after the decimal point, e.g. `1.50`.
+func (d Decimal) String() string {
+	if d.scale <= 0 {
+		return d.rescale(0).String()
+	}
+
+	s := new(big.Int).Abs(d.unscaled()).String()
+	if n := int(d.scale) + 1 - len(s); n > 0 {
+		s = strings.Repeat("0", n) + s
+	}
+
+	i := len(s) - int(d.scale)
+	s = s[:i] + "." + s[i:]
+	if d.Sign() < 0 {
+		s = "-" + s
+	}
+
+	return s
+}
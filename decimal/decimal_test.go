@@ -0,0 +1,154 @@
+package decimal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{input: "1", expected: "1"},
+		{input: "-1.25", expected: "-1.25"},
+		{input: "0.001", expected: "0.001"},
+		{input: ".5", expected: "0.5"},
+		{input: "1.5e3", expected: "1500"},
+		{input: "1.5E-3", expected: "0.0015"},
+		{input: "+2.50", expected: "2.50"},
+		{input: "", expectErr: true},
+		{input: ".", expectErr: true},
+		{input: "1.-5", expectErr: true},
+		{input: "1e", expectErr: true},
+		{input: "0x10", expectErr: true},
+	}
+
+	for _, c := range cases {
+		d, err := Parse(c.input)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("expect error, got nil, input: %s", c.input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, input: %s", err, c.input)
+			continue
+		}
+
+		if d.String() != c.expected {
+			t.Errorf("expected: %s, got: %s, input: %s", c.expected, d.String(), c.input)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	d := func(s string) Decimal {
+		r, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	quo := func(a, b string, scale int32, mode RoundingMode) string {
+		r, err := d(a).Quo(d(b), scale, mode)
+		if err != nil {
+			return err.Error()
+		}
+		return r.String()
+	}
+
+	rem := func(a, b string) string {
+		r, err := d(a).Rem(d(b))
+		if err != nil {
+			return err.Error()
+		}
+		return r.String()
+	}
+
+	pow := func(a string, n int64) string {
+		r, err := d(a).Pow(n, 4, HalfEven)
+		if err != nil {
+			return err.Error()
+		}
+		return r.String()
+	}
+
+	cases := []struct {
+		actual   string
+		expected string
+	}{
+		{actual: d("0.1").Add(d("0.2")).String(), expected: "0.3"},
+		{actual: d("1").Sub(d("0.01")).String(), expected: "0.99"},
+		{actual: d("1.5").Mul(d("-2")).String(), expected: "-3.0"},
+		{actual: d("-2.5").Neg().String(), expected: "2.5"},
+		{actual: d("-2.5").Abs().String(), expected: "2.5"},
+		{actual: quo("10", "4", 16, HalfEven), expected: "2.5"},
+		{actual: quo("1.50", "1", 16, HalfEven), expected: "1.50"},
+		{actual: quo("1", "3", 4, HalfEven), expected: "0.3333"},
+		{actual: quo("2", "3", 4, HalfEven), expected: "0.6667"},
+		{actual: quo("2", "3", 4, Down), expected: "0.6666"},
+		{actual: quo("-2", "3", 4, Floor), expected: "-0.6667"},
+		{actual: quo("-2", "3", 4, Ceiling), expected: "-0.6666"},
+		{actual: quo("1", "0", 4, HalfEven), expected: ErrDivisionByZero.Error()},
+		{actual: rem("10", "3"), expected: "1"},
+		{actual: rem("-5.5", "2"), expected: "-1.5"},
+		{actual: rem("1", "0"), expected: ErrDivisionByZero.Error()},
+		{actual: pow("1.1", 2), expected: "1.21"},
+		{actual: pow("2", -2), expected: "0.25"},
+		{actual: pow("3", -1), expected: "0.3333"},
+		{actual: d("2.345").Round(2, HalfEven).String(), expected: "2.34"},
+		{actual: d("2.355").Round(2, HalfEven).String(), expected: "2.36"},
+		{actual: d("2.345").Round(2, HalfUp).String(), expected: "2.35"},
+		{actual: d("-2.345").Round(2, HalfDown).String(), expected: "-2.34"},
+		{actual: d("-2.341").Round(2, Up).String(), expected: "-2.35"},
+		{actual: d("2.349").Round(2, Down).String(), expected: "2.34"},
+		{actual: d("2.3").Round(2, Down).String(), expected: "2.3"},
+		{actual: d("1.500").Trim(0).String(), expected: "1.5"},
+		{actual: d("1.000").Trim(1).String(), expected: "1.0"},
+		{actual: d("-7.9").Int().String(), expected: "-7"},
+		{actual: d("0.75").Rat().String(), expected: "3/4"},
+		{actual: New(5, -2).String(), expected: "500"},
+		{actual: New(-5, 3).String(), expected: "-0.005"},
+	}
+
+	for i, c := range cases {
+		if c.actual != c.expected {
+			t.Errorf("case %d, expected: %s, got: %s", i, c.expected, c.actual)
+		}
+	}
+
+	if d("1.10").Cmp(d("1.1")) != 0 || d("1.1").Cmp(d("1.2")) != -1 || d("-1").Sign() != -1 {
+		t.Errorf("unexpected comparison result")
+	}
+
+	if !d("2.000").IsInteger() || d("2.001").IsInteger() {
+		t.Errorf("unexpected integer check result")
+	}
+
+	if f := d("0.1").Float64(); f != 0.1 {
+		t.Errorf("expected: 0.1, got: %v", f)
+	}
+
+	if r, err := NewFromFloat(0.1); err != nil || r.String() != "0.1" {
+		t.Errorf("expected: 0.1, got: %v, %v", r, err)
+	}
+
+	if _, err := Parse("1e2000000000"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow error, got: %v", err)
+	}
+	if _, err := d("9").Pow(387420489, 4, HalfEven); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow error, got: %v", err)
+	}
+	if _, err := d("0.1").Pow(1<<40, 4, HalfEven); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow error, got: %v", err)
+	}
+
+	if mode, err := ParseRoundingMode("HALF_UP"); err != nil || mode != HalfUp || mode.String() != "half_up" {
+		t.Errorf("expected: half_up, got: %v, %v", mode, err)
+	}
+}
@@ -0,0 +1,122 @@
+package calc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xwjdsh/calc/ast"
+	"github.com/xwjdsh/calc/operator"
+)
+
+// Kind is the class of Error.
+type Kind int
+
+const (
+	// KindSyntax means the expressions could not be parsed.
+	KindSyntax Kind = iota + 1
+	// KindUnknownVariable means the variable is not found.
+	KindUnknownVariable
+	// KindTypeMismatch means the value types are unsupported.
+	KindTypeMismatch
+	// KindDivisionByZero means the divisor is zero.
+	KindDivisionByZero
+	// KindArity means the arguments count mismatches the function.
+	KindArity
+	// KindRuntime means the other errors during evaluation, e.g. the error returned by custom function.
+	KindRuntime
+	// KindNotFinite means the function result is NaN or infinite, e.g. `ln(0)`.
+	KindNotFinite
+	// KindIncompatibleUnits means the units of quantities have different dimensions, e.g. `1 m + 1 s`.
+	KindIncompatibleUnits
+	// KindMixedCurrencies means the money of different currencies are mixed without conversion, e.g. `1 USD + 1 EUR`.
+	KindMixedCurrencies
+)
+
+// The sentinel errors of each kind, e.g. errors.Is(err, calc.ErrDivisionByZero).
+var (
+	ErrSyntax            = errors.New("syntax error")
+	ErrUnknownVariable   = errors.New("unknown variable")
+	ErrTypeMismatch      = errors.New("type mismatch")
+	ErrDivisionByZero    = errors.New("division by zero")
+	ErrArity             = errors.New("invalid arguments count")
+	ErrRuntime           = errors.New("runtime error")
+	ErrNotFinite         = errors.New("result is not finite")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+	ErrMixedCurrencies   = errors.New("mixed currencies")
+)
+
+var kindErrors = map[Kind]error{
+	KindSyntax:            ErrSyntax,
+	KindUnknownVariable:   ErrUnknownVariable,
+	KindTypeMismatch:      ErrTypeMismatch,
+	KindDivisionByZero:    ErrDivisionByZero,
+	KindArity:             ErrArity,
+	KindRuntime:           ErrRuntime,
+	KindNotFinite:         ErrNotFinite,
+	KindIncompatibleUnits: ErrIncompatibleUnits,
+	KindMixedCurrencies:   ErrMixedCurrencies,
+}
+
+func (k Kind) String() string {
+	if err, ok := kindErrors[k]; ok {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Error is the error of compiling or evaluating expressions, use errors.As to fetch it.
+type Error struct {
+	Kind Kind
+	// Pos is the position of Token in expressions.
+	Pos ast.Pos
+	// Token is the offending token, e.g. the operator or the variable name.
+	Token string
+	Msg   string
+	// Err is the underlying error, it may be nil.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("calc: %d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the sentinel error of e.Kind.
+func (e *Error) Is(target error) bool {
+	return kindErrors[e.Kind] == target
+}
+
+func newError(kind Kind, pos ast.Pos, token string, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Pos: pos, Token: token, Msg: fmt.Sprintf(format, args...)}
+}
+
+// operatorPrefix is the prefix of the operator errors, it is removed from Msg since Error has the prefix `calc: `.
+const operatorPrefix = "calc/operator: "
+
+// wrapError converts the error returned by operator.
+func wrapError(err error, pos ast.Pos, token string) *Error {
+	kind := KindRuntime
+	var currencyErr *operator.CurrencyError
+	switch {
+	case errors.Is(err, operator.ErrInvalidArguments):
+		kind = KindTypeMismatch
+	case errors.Is(err, operator.ErrArgsCount):
+		kind = KindArity
+	case errors.Is(err, operator.ErrDivisionByZero):
+		kind = KindDivisionByZero
+	case errors.Is(err, operator.ErrNotFinite):
+		kind = KindNotFinite
+	case errors.Is(err, operator.ErrIncompatibleUnits):
+		kind = KindIncompatibleUnits
+	case errors.As(err, &currencyErr):
+		kind = KindMixedCurrencies
+	}
+
+	return &Error{Kind: kind, Pos: pos, Token: token, Msg: strings.TrimPrefix(err.Error(), operatorPrefix), Err: err}
+}
@@ -0,0 +1,92 @@
+package operator
+
+// The bitwise operators work on the integer values of any number type, e.g. `0xFF & 0b1010` or `1 << 4`,
+// the negative integers are in two's complement like C, and the results are int64 or *big.Int.
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/xwjdsh/calc/decimal"
+)
+
+// maxShiftBits is the maximum bits count of the shift left result.
+const maxShiftBits = maxIntPowBits
+
+// isBaseLiteral reports whether the literal is a hexadecimal, binary or octal integer, e.g. `0xFF`, `0b1010` or `0o17`,
+// the literal with leading zero like `017` is decimal.
+func isBaseLiteral(literal string) bool {
+	return len(literal) > 2 && literal[0] == '0' && strings.ContainsRune("xXbBoO", rune(literal[1]))
+}
+
+// maxExactFloat is the maximum magnitude of float64 whose integers are all exact, it is 2^53.
+const maxExactFloat = 1 << 53
+
+// integerValue returns the value of number v if it is an integer, the float64 above 2^53 is rejected since
+// it may be rounded, e.g. the literal `18446744073709551615` is 2^64 in float64.
+func integerValue(v interface{}) (*big.Int, bool) {
+	if i, ok := toBigInt(v); ok {
+		return i, true
+	}
+
+	switch n := v.(type) {
+	case float64:
+		if math.Abs(n) > maxExactFloat || n != math.Trunc(n) {
+			return nil, false
+		}
+		return big.NewInt(int64(n)), true
+	case decimal.Decimal:
+		if n.IsInteger() {
+			return n.Int(), true
+		}
+	case *big.Rat:
+		if n.IsInt() {
+			return new(big.Int).Set(n.Num()), true
+		}
+	}
+
+	return nil, false
+}
+
+// bitwise applies the bitwise operator t on the integers a and b, CARET is the exclusive or.
+func bitwise(t Token, a, b interface{}) (interface{}, error) {
+	i1, ok1 := integerValue(a)
+	i2, ok2 := integerValue(b)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%w for code: %s", ErrInvalidArguments, t)
+	}
+
+	r := new(big.Int)
+	switch t {
+	case AND:
+		r.And(i1, i2)
+	case OR:
+		r.Or(i1, i2)
+	case CARET:
+		r.Xor(i1, i2)
+	case SHL, SHR:
+		n, ok := toInt(i2)
+		if !ok || n < 0 || (t == SHL && int64(i1.BitLen())+n > maxShiftBits) {
+			return nil, fmt.Errorf("%w for code: %s", ErrInvalidArguments, t)
+		}
+		if t == SHL {
+			r.Lsh(i1, uint(n))
+		} else {
+			r.Rsh(i1, uint(n))
+		}
+	}
+
+	return normalizeInt(r), nil
+}
+
+// bitwiseNot returns ^v of integer v, it equals to -v-1.
+func bitwiseNot(v interface{}) (interface{}, error) {
+	i, ok := integerValue(v)
+	if !ok {
+		return nil, fmt.Errorf("%w for code: %s", ErrInvalidArguments, TILDE)
+	}
+
+	return normalizeInt(new(big.Int).Not(i)), nil
+}
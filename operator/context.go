@@ -0,0 +1,126 @@
+package operator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/xwjdsh/calc/decimal"
+)
+
+// DefaultDecimalPrecision is the default digits count after the decimal point of inexact decimal results.
+const DefaultDecimalPrecision = 16
+
+// Division determines the result of dividing integers.
+type Division int
+
+const (
+	// FloatDivision returns float64 if the quotient is not an integer, e.g. `7/2` is 3.5 and `6/2` is 3.
+	FloatDivision Division = iota
+	// TruncatedDivision rounds the quotient toward zero, e.g. `-7/2` is -3.
+	TruncatedDivision
+	// FloorDivision rounds the quotient toward negative infinity, e.g. `-7/2` is -4.
+	FloorDivision
+)
+
+// AngleUnit is the unit of angles of trigonometric functions.
+type AngleUnit int
+
+const (
+	// Radians is the default unit, a full turn is 2*pi.
+	Radians AngleUnit = iota
+	// Degrees makes a full turn 360.
+	Degrees
+	// Gradians makes a full turn 400.
+	Gradians
+)
+
+var (
+	angleUnitNames     = []string{"rad", "deg", "grad"}
+	fullAngleUnitNames = []string{"radians", "degrees", "gradians"}
+)
+
+func (u AngleUnit) String() string {
+	if u >= 0 && int(u) < len(angleUnitNames) {
+		return angleUnitNames[u]
+	}
+
+	return fmt.Sprintf("AngleUnit(%d)", int(u))
+}
+
+// ParseAngleUnit returns the angle unit by name, `rad`, `deg` or `grad`, the full names like `degrees` are accepted too.
+func ParseAngleUnit(s string) (AngleUnit, error) {
+	for i, name := range angleUnitNames {
+		if strings.EqualFold(s, name) || strings.EqualFold(s, fullAngleUnitNames[i]) {
+			return AngleUnit(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("calc/operator: unknown angle unit: %s", s)
+}
+
+// fullTurn returns the angle of a full turn.
+func (u AngleUnit) fullTurn() float64 {
+	switch u {
+	case Degrees:
+		return 360
+	case Gradians:
+		return 400
+	}
+
+	return 2 * math.Pi
+}
+
+// Context is the settings shared by the operators of a Manager, it should not be changed after the Manager is used.
+type Context struct {
+	// DecimalPrecision is the digits count after the decimal point of inexact decimal results, e.g. `1/3`.
+	DecimalPrecision int32
+	// Rounding is the rounding mode of inexact decimal results.
+	Rounding decimal.RoundingMode
+	// Division determines the result of dividing integers.
+	Division Division
+	// Numbers is the type of number literals and variables, the functions create numbers of it, e.g. `len('a')`.
+	Numbers NumberType
+	// AngleUnit is the unit of the arguments of trigonometric functions, and the results of inverse ones.
+	AngleUnit AngleUnit
+	// Now returns the current time of `now()`, it is time.Now if nil, e.g. a fixed time for tests.
+	Now func() time.Time
+	// CaretXor makes `^` the bitwise exclusive or like C, instead of the exponent, `**` is the exponent still.
+	CaretXor bool
+	// Rates provides the exchange rates of money conversion, e.g. `100 USD in EUR`, it is an error if nil.
+	Rates RateProvider
+
+	// regexps caches the compiled patterns of regular expression functions
+	regexps *regexpCache
+}
+
+// NewContext returns a Context with default settings.
+func NewContext() *Context {
+	return &Context{
+		DecimalPrecision: DefaultDecimalPrecision,
+		Rounding:         decimal.HalfEven,
+		regexps:          newRegexpCache(),
+	}
+}
+
+// the operators created without Manager use the default settings.
+var defaultContext = NewContext()
+
+// toRadians converts the angle in unit of the context to radians.
+func (c *Context) toRadians(v float64) float64 {
+	if c.AngleUnit == Radians {
+		return v
+	}
+
+	return v * 2 * math.Pi / c.AngleUnit.fullTurn()
+}
+
+// fromRadians converts the angle in radians to unit of the context.
+func (c *Context) fromRadians(v float64) float64 {
+	if c.AngleUnit == Radians {
+		return v
+	}
+
+	return v * c.AngleUnit.fullTurn() / (2 * math.Pi)
+}
@@ -0,0 +1,329 @@
+package operator
+
+// The financial functions are compatible with spreadsheets, the cash paid out is negative, e.g. `pmt(0.05/12, 360, 100000)`
+// is the negative monthly payment of the loan. The optional type is 0 if the payments are due at the end of periods,
+// or 1 if they are due at the beginning. The results are exact in decimal mode except the ones need logarithms or iterations,
+// they are float64 in the other modes, since the integers are truncated by `/` and the rational `(1+rate)^nper` is huge.
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/xwjdsh/calc/decimal"
+)
+
+const (
+	// maxIterations is the maximum iterations of solving rate and irr.
+	maxIterations = 100
+	// iterationTolerance is the precision of the rate and irr results.
+	iterationTolerance = 1e-10
+)
+
+// calculation applies the arithmetic operators in sequence, it keeps the first error and ignores the rest operations,
+// e.g. `c.op(ADD, c.op(MUL, a, b), 1)` and then check c.err. The operands are decimals in decimal mode, or float64
+// in the other modes regardless of the Division.
+type calculation struct {
+	ctx *Context
+	err error
+}
+
+func (c *calculation) op(t Token, a, b interface{}) interface{} {
+	if c.err != nil {
+		return nil
+	}
+
+	r, err := c.ctx.apply(t, c.number(a), c.number(b))
+	c.err = err
+	return r
+}
+
+// result returns the result r of calculation, the decimal is rounded by the context without trailing zeros,
+// since the exact `(1+rate)^nper` has too many digits.
+func (c *calculation) result(r interface{}) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if d, ok := r.(decimal.Decimal); ok {
+		return d.Round(c.ctx.DecimalPrecision, c.ctx.Rounding).Trim(0), nil
+	}
+
+	return r, nil
+}
+
+// number converts the real number v to decimal in decimal mode, or float64 in the other modes.
+func (c *calculation) number(v interface{}) interface{} {
+	if c.ctx.Numbers == DecimalNumbers {
+		if d, ok := c.ctx.toDecimal(v); ok {
+			return d
+		}
+	} else if f, ok := toFloat(v); ok {
+		return f
+	}
+
+	return v
+}
+
+// isZero reports whether the number v is zero.
+func isZero(v interface{}) bool {
+	eq, ok := equalNumbers(v, int64(0))
+	return ok && eq
+}
+
+// optional returns the i-th argument, or the default value v if it is absent.
+func optional(args []interface{}, i int, v interface{}) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+
+	return v
+}
+
+// annuity returns `(1+rate*type)` shared by pmt, pv, fv and nper, the payment type should be 0 or 1.
+func annuity(c *calculation, rate, typ interface{}) (interface{}, bool) {
+	if !isZero(typ) {
+		if eq, ok := equalNumbers(typ, int64(1)); !ok || !eq {
+			c.err = ErrInvalidArguments
+			return nil, false
+		}
+	}
+
+	return c.op(ADD, int64(1), c.op(MUL, rate, typ)), true
+}
+
+// pmt returns the payment per period, `pmt(rate, nper, pv, [fv], [type])`.
+func pmt(ctx *Context, args ...interface{}) (interface{}, error) {
+	rate, nper, pv, fv := args[0], args[1], args[2], optional(args, 3, int64(0))
+	c := &calculation{ctx: ctx}
+	adj, ok := annuity(c, rate, optional(args, 4, int64(0)))
+	if !ok {
+		return nil, c.err
+	}
+
+	var r interface{}
+	if isZero(rate) {
+		// -(pv+fv)/nper
+		r = c.op(QUO, c.op(ADD, pv, fv), c.op(MUL, nper, int64(-1)))
+	} else {
+		// -(fv+pv*f)*rate/(adj*(f-1)), f = (1+rate)^nper
+		f := c.op(CARET, c.op(ADD, int64(1), rate), nper)
+		num := c.op(MUL, c.op(ADD, fv, c.op(MUL, pv, f)), c.op(MUL, rate, int64(-1)))
+		r = c.op(QUO, num, c.op(MUL, adj, c.op(SUB, f, int64(1))))
+	}
+
+	return c.result(r)
+}
+
+// pv returns the present value, `pv(rate, nper, pmt, [fv], [type])`.
+func pv(ctx *Context, args ...interface{}) (interface{}, error) {
+	rate, nper, payment, fv := args[0], args[1], args[2], optional(args, 3, int64(0))
+	c := &calculation{ctx: ctx}
+	adj, ok := annuity(c, rate, optional(args, 4, int64(0)))
+	if !ok {
+		return nil, c.err
+	}
+
+	var r interface{}
+	if isZero(rate) {
+		// -(fv+pmt*nper)
+		r = c.op(MUL, c.op(ADD, fv, c.op(MUL, payment, nper)), int64(-1))
+	} else {
+		// -(fv+pmt*adj*(f-1)/rate)/f
+		f := c.op(CARET, c.op(ADD, int64(1), rate), nper)
+		s := c.op(QUO, c.op(MUL, c.op(MUL, payment, adj), c.op(SUB, f, int64(1))), rate)
+		r = c.op(QUO, c.op(ADD, fv, s), c.op(MUL, f, int64(-1)))
+	}
+
+	return c.result(r)
+}
+
+// fv returns the future value, `fv(rate, nper, pmt, [pv], [type])`.
+func fv(ctx *Context, args ...interface{}) (interface{}, error) {
+	rate, nper, payment, pv := args[0], args[1], args[2], optional(args, 3, int64(0))
+	c := &calculation{ctx: ctx}
+	adj, ok := annuity(c, rate, optional(args, 4, int64(0)))
+	if !ok {
+		return nil, c.err
+	}
+
+	var r interface{}
+	if isZero(rate) {
+		// -(pv+pmt*nper)
+		r = c.op(MUL, c.op(ADD, pv, c.op(MUL, payment, nper)), int64(-1))
+	} else {
+		// -(pv*f+pmt*adj*(f-1)/rate)
+		f := c.op(CARET, c.op(ADD, int64(1), rate), nper)
+		s := c.op(QUO, c.op(MUL, c.op(MUL, payment, adj), c.op(SUB, f, int64(1))), rate)
+		r = c.op(MUL, c.op(ADD, c.op(MUL, pv, f), s), int64(-1))
+	}
+
+	return c.result(r)
+}
+
+// nper returns the number of periods, `nper(rate, pmt, pv, [fv], [type])`.
+func nper(ctx *Context, args ...interface{}) (interface{}, error) {
+	rate, payment, pv, fv := args[0], args[1], args[2], optional(args, 3, int64(0))
+	c := &calculation{ctx: ctx}
+	adj, ok := annuity(c, rate, optional(args, 4, int64(0)))
+	if !ok {
+		return nil, c.err
+	}
+
+	if isZero(rate) {
+		// -(pv+fv)/pmt
+		r := c.op(QUO, c.op(ADD, pv, fv), c.op(MUL, payment, int64(-1)))
+		return c.result(r)
+	}
+
+	// ln((pmt*adj-fv*rate)/(pmt*adj+pv*rate))/ln(1+rate)
+	p := c.op(MUL, payment, adj)
+	x := c.op(QUO, c.op(SUB, p, c.op(MUL, fv, rate)), c.op(ADD, p, c.op(MUL, pv, rate)))
+	y := c.op(ADD, int64(1), rate)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	fx, _ := toFloat(x)
+	fy, _ := toFloat(y)
+	return ctx.fromFloat(math.Log(fx)/math.Log(fy), kindOf(x))
+}
+
+// rate returns the interest rate per period, `rate(nper, pmt, pv, [fv], [type], [guess])`,
+// it is solved by Newton's method in float64.
+func rate(ctx *Context, args ...interface{}) (interface{}, error) {
+	fs := make([]float64, 6)
+	for i, v := range []interface{}{args[0], args[1], args[2], optional(args, 3, int64(0)), optional(args, 4, int64(0)), optional(args, 5, 0.1)} {
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+		fs[i] = f
+	}
+
+	n, payment, pv, fv, typ, guess := fs[0], fs[1], fs[2], fs[3], fs[4], fs[5]
+	if typ != 0 && typ != 1 {
+		return nil, ErrInvalidArguments
+	}
+
+	// the future value of all the cash flows should be zero
+	r, err := solve(guess, func(r float64) float64 {
+		if r == 0 {
+			return pv + payment*n + fv
+		}
+		f := math.Pow(1+r, n)
+		return pv*f + payment*(1+r*typ)*(f-1)/r + fv
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, k := unify(args[1], args[2])
+	return ctx.fromFloat(r, k)
+}
+
+// npv returns the net present value of the cash flows at the end of periods, `npv(rate, value1, value2, ...)`.
+func npv(ctx *Context, args ...interface{}) (interface{}, error) {
+	values, _, err := numbers(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	c := &calculation{ctx: ctx}
+	rate := c.op(ADD, int64(1), args[0])
+	var r interface{} = int64(0)
+	for i, v := range values {
+		r = c.op(ADD, r, c.op(QUO, v, c.op(CARET, rate, int64(i+1))))
+	}
+
+	return c.result(r)
+}
+
+// irr returns the internal rate of return of the cash flows, `irr(value1, value2, ...)`,
+// the first value is usually the negative investment. It is solved by Newton's method in float64.
+func irr(ctx *Context, args ...interface{}) (interface{}, error) {
+	values, k, err := numbers(args)
+	if err != nil || k == complexKind {
+		return nil, ErrInvalidArguments
+	}
+
+	fs := make([]float64, len(values))
+	for i, v := range values {
+		fs[i], _ = toFloat(v)
+	}
+
+	// the net present value at period 0 should be zero
+	r, err := solve(0.1, func(r float64) float64 {
+		var s float64
+		for i, f := range fs {
+			s += f / math.Pow(1+r, float64(i))
+		}
+		return s
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.fromFloat(r, k)
+}
+
+// solve returns the root of f near guess by Newton's method, the derivative is approximated by the difference.
+func solve(guess float64, f func(float64) float64) (float64, error) {
+	x := guess
+	for i := 0; i < maxIterations; i++ {
+		y := f(x)
+		if math.Abs(y) < iterationTolerance {
+			return x, nil
+		}
+
+		h := math.Max(math.Abs(x)*1e-6, 1e-10)
+		d := (f(x+h) - y) / h
+		if d == 0 || math.IsNaN(d) || math.IsInf(d, 0) {
+			break
+		}
+
+		next := x - y/d
+		if math.Abs(next-x) < iterationTolerance {
+			return next, nil
+		}
+		x = next
+	}
+
+	return 0, ErrNotConverged
+}
+
+// round2 rounds the number to 2 or the given digits after the decimal point by banker's rounding, `round2(x, [digits])`,
+// e.g. `round2(2.345)` is 2.34, the amount of money is rounded too. The float64 is rounded by its shortest decimal
+// representation.
+func round2(_ *Context, args ...interface{}) (interface{}, error) {
+	d, ok := toInt(optional(args, 1, int64(2)))
+	if !ok || d > math.MaxInt32 || d < math.MinInt32 {
+		return nil, ErrInvalidArguments
+	}
+	digits := int32(d)
+
+	switch n := args[0].(type) {
+	case int64, *big.Int:
+		if digits >= 0 {
+			return n, nil
+		}
+		i, _ := toBigInt(n)
+		return normalizeInt(decimal.NewFromBigInt(i, 0).Round(digits, decimal.HalfEven).Int()), nil
+	case decimal.Decimal:
+		return n.Round(digits, decimal.HalfEven), nil
+	case Money:
+		return Money{Amount: n.Amount.Round(digits, decimal.HalfEven), Currency: n.Currency}, nil
+	case float64:
+		v, err := decimal.NewFromFloat(n)
+		if err != nil {
+			return nil, ErrInvalidArguments
+		}
+		return v.Round(digits, decimal.HalfEven).Float64(), nil
+	case *big.Rat:
+		v, err := decimal.NewFromBigInt(n.Num(), 0).Quo(decimal.NewFromBigInt(n.Denom(), 0), digits, decimal.HalfEven)
+		if err != nil {
+			return nil, err
+		}
+		return v.Rat(), nil
+	}
+
+	return nil, ErrInvalidArguments
+}
@@ -0,0 +1,432 @@
+package operator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"strings"
+	"time"
+
+	"github.com/xwjdsh/calc/decimal"
+)
+
+// Func is the handler of function type operators, args are the values of call arguments,
+// value types are float64, int64, *big.Int, decimal.Decimal, *big.Rat, complex128, string, bool,
+// time.Time, time.Duration and []interface{}.
+// It could return ErrInvalidArguments if the argument types are unsupported.
+type Func func(args ...interface{}) (interface{}, error)
+
+// builtinFunc is the handler of builtin functions, which could access the settings of Manager.
+type builtinFunc func(ctx *Context, args ...interface{}) (interface{}, error)
+
+type function struct {
+	minArgs, maxArgs int
+	fn               builtinFunc
+}
+
+var builtinFunctions = map[Token]function{
+	SIN: {1, 1, trigFunc(math.Sin, cmplx.Sin, [4]float64{0, 1, 0, -1})},
+	COS: {1, 1, trigFunc(math.Cos, cmplx.Cos, [4]float64{1, 0, -1, 0})},
+	TAN: {1, 1, trigFunc(math.Tan, cmplx.Tan, [4]float64{0, math.Inf(1), 0, math.Inf(1)})},
+	ABS: {1, 1, numberFunc(absolute)},
+	OPP: {1, 1, numberFunc(negate)},
+	SUM: {1, Variadic, sum},
+	MAX: {1, Variadic, extremum(1)},
+	MIN: {1, Variadic, extremum(-1)},
+	POW: {2, 2, pow},
+
+	SQRT: {1, 1, squareRoot},
+	EXP:  {1, 1, floatFunc(math.Exp, cmplx.Exp)},
+	CONJ: {1, 1, numberFunc(conjugate)},
+	ARG:  {1, 1, complexFunc(cmplx.Phase)},
+	RE:   {1, 1, complexFunc(func(c complex128) float64 { return real(c) })},
+	IM:   {1, 1, complexFunc(func(c complex128) float64 { return imag(c) })},
+
+	CBRT:  {1, 1, floatFunc(math.Cbrt, nil)},
+	LN:    {1, 1, floatFunc(math.Log, cmplx.Log)},
+	LOG:   {1, 2, logarithm},
+	LOG2:  {1, 1, floatFunc(math.Log2, nil)},
+	LOG10: {1, 1, floatFunc(math.Log10, cmplx.Log10)},
+	ASIN:  {1, 1, inverseTrigFunc(math.Asin, cmplx.Asin)},
+	ACOS:  {1, 1, inverseTrigFunc(math.Acos, cmplx.Acos)},
+	ATAN:  {1, 1, inverseTrigFunc(math.Atan, cmplx.Atan)},
+	ATAN2: {2, 2, atan2},
+	SINH:  {1, 1, floatFunc(math.Sinh, cmplx.Sinh)},
+	COSH:  {1, 1, floatFunc(math.Cosh, cmplx.Cosh)},
+	TANH:  {1, 1, floatFunc(math.Tanh, cmplx.Tanh)},
+	FLOOR: {1, 1, roundFunc(math.Floor, decimal.Floor)},
+	CEIL:  {1, 1, roundFunc(math.Ceil, decimal.Ceiling)},
+	ROUND: {1, 1, roundFunc(math.Round, decimal.HalfUp)},
+	TRUNC: {1, 1, roundFunc(math.Trunc, decimal.Down)},
+	HYPOT: {2, 2, floatFunc2(math.Hypot)},
+	MOD:   {2, 2, modulo},
+	SIGN:  {1, 1, numberFunc(sign)},
+
+	DEG: {1, 1, floatFunc(func(v float64) float64 { return v * 180 / math.Pi }, nil)},
+	RAD: {1, 1, floatFunc(func(v float64) float64 { return v * math.Pi / 180 }, nil)},
+
+	AVG:        {1, Variadic, average},
+	MEAN:       {1, Variadic, average},
+	MEDIAN:     {1, Variadic, median},
+	MODE:       {1, Variadic, mode},
+	VARIANCE:   {1, Variadic, variance(1)},
+	PVARIANCE:  {1, Variadic, variance(0)},
+	STDDEV:     {1, Variadic, stddev(1)},
+	PSTDDEV:    {1, Variadic, stddev(0)},
+	PERCENTILE: {2, 2, percentile},
+	COUNT:      {1, Variadic, count},
+	PRODUCT:    {1, Variadic, product},
+	RANGE:      {1, Variadic, valueRange},
+	GEOMEAN:    {1, Variadic, geomean},
+
+	PMT:    {3, 5, pmt},
+	PV:     {3, 5, pv},
+	FV:     {3, 5, fv},
+	NPER:   {3, 5, nper},
+	RATE:   {3, 6, rate},
+	NPV:    {2, Variadic, npv},
+	IRR:    {1, Variadic, irr},
+	ROUND2: {1, 2, round2},
+
+	LEN:        {1, 1, length},
+	UPPER:      {1, 1, stringFunc(func(_ *Context, s string, _ []interface{}) (interface{}, error) { return strings.ToUpper(s), nil })},
+	LOWER:      {1, 1, stringFunc(func(_ *Context, s string, _ []interface{}) (interface{}, error) { return strings.ToLower(s), nil })},
+	TRIM:       {1, 2, stringFunc(trim)},
+	SUBSTR:     {2, 3, stringFunc(substr)},
+	REPLACE:    {3, 4, stringFunc(replace)},
+	CONTAINS:   {2, 2, contains},
+	STARTSWITH: {2, 2, stringFunc(hasPrefix)},
+	ENDSWITH:   {2, 2, stringFunc(hasSuffix)},
+	SPLIT:      {2, 2, stringFunc(split)},
+	JOIN:       {2, 2, join},
+	INDEXOF:    {2, 2, indexOf},
+	PAD:        {2, 3, stringFunc(pad)},
+	FORMAT:     {1, Variadic, stringFunc(format)},
+	STR:        {1, 1, str},
+	NUM:        {1, 1, num},
+
+	MATCHES:       {2, 2, regexpFunc(matches)},
+	REGEX_EXTRACT: {2, 3, regexpFunc(extract)},
+	REGEX_REPLACE: {3, 3, regexpFunc(regexpReplace)},
+
+	NOW:         {0, 0, now},
+	DATE:        {1, 3, date},
+	SECONDS:     {1, 1, durationFunc(time.Second)},
+	MINUTES:     {1, 1, durationFunc(time.Minute)},
+	HOURS:       {1, 1, durationFunc(time.Hour)},
+	DAYS:        {1, 1, durationFunc(24 * time.Hour)},
+	WEEKS:       {1, 1, durationFunc(7 * 24 * time.Hour)},
+	WEEKDAY:     {0, 1, weekday},
+	ADDMONTHS:   {2, 2, addMonths},
+	FORMAT_DATE: {1, 2, formatDate},
+}
+
+type functionOperator struct {
+	token
+	function
+	ctx *Context
+}
+
+// NewFunction returns a function type operator, the name is case-insensitive,
+// maxArgs is Variadic if the function accepts unlimited arguments.
+func NewFunction(name string, minArgs, maxArgs int, fn Func) ExecutableOperator {
+	return &functionOperator{
+		token: Token(strings.ToLower(name)),
+		function: function{minArgs: minArgs, maxArgs: maxArgs, fn: func(_ *Context, args ...interface{}) (interface{}, error) {
+			return fn(args...)
+		}},
+		ctx: defaultContext,
+	}
+}
+
+func newFunctionOperator(t Token) *functionOperator {
+	return &functionOperator{token: t, function: builtinFunctions[t], ctx: defaultContext}
+}
+
+func (o *functionOperator) Type() Type {
+	return Function
+}
+
+func (o *functionOperator) Arity() (int, int) {
+	return o.minArgs, o.maxArgs
+}
+
+func (o *functionOperator) Execute(args []interface{}) (interface{}, error) {
+	// a single list argument is spread if the function requires more than one argument,
+	// e.g. `sum($list)` equals to `sum(1,2,3)`
+	if len(args) == 1 && (o.maxArgs == Variadic || o.minArgs > 1) {
+		if vs, ok := args[0].([]interface{}); ok && len(vs) > 0 {
+			args = vs
+		}
+	}
+
+	if len(args) < o.minArgs || (o.maxArgs != Variadic && len(args) > o.maxArgs) {
+		return nil, fmt.Errorf("%w for code: %s, expected: %s, actual: %d", ErrArgsCount, o.token, o.arityString(), len(args))
+	}
+
+	r, err := o.fn(o.ctx, args...)
+	if err == ErrInvalidArguments {
+		return nil, fmt.Errorf("%w for code: %s", ErrInvalidArguments, o.token)
+	}
+	if err == nil && !isFinite(r) {
+		return nil, fmt.Errorf("%w for code: %s, result: %v", ErrNotFinite, o.token, r)
+	}
+
+	return r, err
+}
+
+func (o *functionOperator) Preference() int {
+	// same as the unary operators
+	if o.token == OPP {
+		return 12
+	}
+
+	return 0
+}
+
+func (o *functionOperator) Associativity() Associativity {
+	return LeftAssociative
+}
+
+func (o *functionOperator) arityString() string {
+	switch {
+	case o.maxArgs == Variadic:
+		return fmt.Sprintf("at least %d", o.minArgs)
+	case o.minArgs == o.maxArgs:
+		return fmt.Sprint(o.minArgs)
+	}
+
+	return fmt.Sprintf("%d to %d", o.minArgs, o.maxArgs)
+}
+
+// floatFunc converts the math function to builtin function, the decimal argument is converted to float64
+// and the result is converted back, the rational argument falls back to float64.
+// The complex argument is handled by cf, it is unsupported if cf is nil.
+func floatFunc(f func(float64) float64, cf func(complex128) complex128) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		if c, ok := args[0].(complex128); ok {
+			if cf == nil {
+				return nil, ErrInvalidArguments
+			}
+			return cf(c), nil
+		}
+
+		v, ok := toFloat(args[0])
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+
+		return ctx.fromFloat(f(v), kindOf(args[0]))
+	}
+}
+
+// trigFunc converts the trigonometric function to builtin function, the real argument is in the angle unit
+// of the context, the complex argument is always in radians. The multiples of quarter turn in degrees and gradians
+// have the exact results of quarters, e.g. `cos(90)` is 0.
+func trigFunc(f func(float64) float64, cf func(complex128) complex128, quarters [4]float64) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		return floatFunc(func(v float64) float64 {
+			if ctx.AngleUnit != Radians {
+				q := v / (ctx.AngleUnit.fullTurn() / 4)
+				if q == math.Trunc(q) && math.Abs(q) < 1<<53 {
+					// the remainder of negative q is negative
+					return quarters[(int64(q)%4+4)%4]
+				}
+			}
+
+			return f(ctx.toRadians(v))
+		}, cf)(ctx, args...)
+	}
+}
+
+// inverseTrigFunc converts the inverse trigonometric function to builtin function,
+// the real result is in the angle unit of the context.
+func inverseTrigFunc(f func(float64) float64, cf func(complex128) complex128) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		return floatFunc(func(v float64) float64 { return ctx.fromRadians(f(v)) }, cf)(ctx, args...)
+	}
+}
+
+func atan2(ctx *Context, args ...interface{}) (interface{}, error) {
+	return floatFunc2(func(y, x float64) float64 { return ctx.fromRadians(math.Atan2(y, x)) })(ctx, args...)
+}
+
+// floatFunc2 converts the math function with two arguments to builtin function like floatFunc,
+// the complex arguments are unsupported.
+func floatFunc2(f func(float64, float64) float64) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		v1, ok1 := toFloat(args[0])
+		v2, ok2 := toFloat(args[1])
+		if !ok1 || !ok2 {
+			return nil, ErrInvalidArguments
+		}
+
+		_, _, k := unify(args[0], args[1])
+		return ctx.fromFloat(f(v1, v2), k)
+	}
+}
+
+// roundFunc converts the float64 rounding function to builtin function,
+// the exact numbers are rounded to integer by mode, the integers are kept.
+func roundFunc(f func(float64) float64, mode decimal.RoundingMode) builtinFunc {
+	return func(_ *Context, args ...interface{}) (interface{}, error) {
+		switch n := args[0].(type) {
+		case int64, *big.Int:
+			return n, nil
+		case float64:
+			return f(n), nil
+		case decimal.Decimal:
+			return n.Round(0, mode), nil
+		case *big.Rat:
+			d, err := decimal.NewFromBigInt(n.Num(), 0).Quo(decimal.NewFromBigInt(n.Denom(), 0), 0, mode)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(d.Int()), nil
+		}
+
+		return nil, ErrInvalidArguments
+	}
+}
+
+// complexFunc converts the real-valued complex function to builtin function,
+// the real argument is converted to complex128.
+func complexFunc(f func(complex128) float64) builtinFunc {
+	return func(_ *Context, args ...interface{}) (interface{}, error) {
+		c, ok := convert(args[0], complexKind).(complex128)
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+
+		return f(c), nil
+	}
+}
+
+// squareRoot returns the complex root of negative number, e.g. `sqrt(-1)` is `1i`.
+func squareRoot(ctx *Context, args ...interface{}) (interface{}, error) {
+	if f, ok := toFloat(args[0]); ok && f < 0 {
+		return cmplx.Sqrt(complex(f, 0)), nil
+	}
+
+	return floatFunc(math.Sqrt, cmplx.Sqrt)(ctx, args...)
+}
+
+// numberFunc converts the exact number function to builtin function.
+func numberFunc(f func(interface{}) (interface{}, bool)) builtinFunc {
+	return func(_ *Context, args ...interface{}) (interface{}, error) {
+		if r, ok := f(args[0]); ok {
+			return r, nil
+		}
+
+		return nil, ErrInvalidArguments
+	}
+}
+
+func sum(ctx *Context, args ...interface{}) (interface{}, error) {
+	args = flatten(args)
+
+	r := ctx.Numbers.fromInt(0)
+	for i, arg := range args {
+		if i == 0 {
+			if !isNumber(arg) {
+				return nil, ErrInvalidArguments
+			}
+			r = arg
+			continue
+		}
+
+		v, ok, err := ctx.arithmetic(ADD, r, arg)
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+		if err != nil {
+			return nil, err
+		}
+		r = v
+	}
+
+	return r, nil
+}
+
+// extremum returns the maximum function if sign is 1, or the minimum function if sign is -1.
+// The arguments should be all numbers or all strings.
+func extremum(sign int) builtinFunc {
+	return func(_ *Context, args ...interface{}) (interface{}, error) {
+		args = flatten(args)
+
+		var r interface{}
+		for i, arg := range args {
+			if i == 0 {
+				r = arg
+				continue
+			}
+
+			c, ok := compareNumbers(arg, r)
+			if !ok {
+				s1, ok1 := arg.(string)
+				s2, ok2 := r.(string)
+				if !ok1 || !ok2 {
+					return nil, ErrInvalidArguments
+				}
+				c = strings.Compare(s1, s2)
+			}
+
+			if c == sign {
+				r = arg
+			}
+		}
+
+		if _, ok := r.(string); ok || isNumber(r) {
+			return r, nil
+		}
+		return nil, ErrInvalidArguments
+	}
+}
+
+// logarithm returns the common logarithm of x by `log(x)`, or the logarithm of base b by `log(x, b)`.
+func logarithm(ctx *Context, args ...interface{}) (interface{}, error) {
+	if len(args) == 1 {
+		return floatFunc(math.Log10, cmplx.Log10)(ctx, args...)
+	}
+
+	return floatFunc2(func(x, b float64) float64 { return math.Log(x) / math.Log(b) })(ctx, args...)
+}
+
+// modulo returns the remainder of x/y with the sign of x, the float numbers are supported unlike `%`.
+func modulo(ctx *Context, args ...interface{}) (interface{}, error) {
+	if x, y, k := unify(args[0], args[1]); k == floatKind {
+		if y == 0.0 {
+			return nil, ErrDivisionByZero
+		}
+		return math.Mod(x.(float64), y.(float64)), nil
+	}
+
+	if r, ok, err := ctx.arithmetic(REM, args[0], args[1]); ok {
+		return r, err
+	}
+
+	return nil, ErrInvalidArguments
+}
+
+func pow(ctx *Context, args ...interface{}) (interface{}, error) {
+	if r, ok, err := ctx.arithmetic(CARET, args[0], args[1]); ok {
+		return r, err
+	}
+
+	return nil, ErrInvalidArguments
+}
+
+// flatten spreads the nested lists, e.g. `sum((1,2),3)` equals to `sum(1,2,3)`.
+func flatten(args []interface{}) []interface{} {
+	var r []interface{}
+	for _, arg := range args {
+		if vs, ok := arg.([]interface{}); ok {
+			r = append(r, flatten(vs)...)
+			continue
+		}
+		r = append(r, arg)
+	}
+
+	return r
+}
@@ -1,40 +1,100 @@
 package operator
 
+import (
+	"fmt"
+	"sync"
+	"unicode"
+)
+
 // Manager manage all available operator.
 type Manager struct {
-	m map[token]Operator
+	mu  sync.RWMutex
+	m   map[Token]Operator
+	ctx *Context
 }
 
 // NewManager returns a new manager instance.
 func NewManager() *Manager {
-	m := map[token]Operator{}
+	ctx := NewContext()
+	m := map[Token]Operator{}
 	// register general type operators
-	for _, c := range []token{ADD, SUB, MUL, QUO, REM, COMMA} {
-		m[c] = newGeneralOperator(c)
+	for _, c := range []Token{ADD, SUB, MUL, QUO, REM, COMMA, EQL, NEQ, LSS, LEQ, GTR, GEQ, LAND, LOR, CARET, DSTAR, MATCH, TO, IN, AND, OR, SHL, SHR} {
+		op := newGeneralOperator(c)
+		op.ctx = ctx
+		m[c] = op
+	}
+
+	// register unary type operators
+	for _, c := range []Token{NOT, TILDE} {
+		m[c] = newUnaryOperator(c)
 	}
 
 	// register bracket type operators
-	for _, c := range []token{LPAREN, RPAREN} {
+	for _, c := range []Token{LPAREN, RPAREN} {
 		m[c] = newBracketOperator(c)
 	}
 
 	// register function type operators
-	for _, c := range []token{SIN, COS, TAN, ABS, OPP, SUM, MAX, MIN, POW} {
-		m[c] = newFunctionOperator(c)
+	for c := range builtinFunctions {
+		op := newFunctionOperator(c)
+		op.ctx = ctx
+		m[c] = op
 	}
 
 	return &Manager{
-		m: m,
+		m:   m,
+		ctx: ctx,
 	}
 }
 
+// Context returns the settings shared by the operators.
+func (m *Manager) Context() *Context {
+	return m.ctx
+}
+
 // Get returns operator by special code, it will be nil if not found.
-func (m *Manager) Get(t token) (Operator, bool) {
+func (m *Manager) Get(t Token) (Operator, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	op, ok := m.m[t]
 	return op, ok
 }
 
 // GetByString same as Get, but accept string.
 func (m *Manager) GetByString(code string) (Operator, bool) {
-	return m.Get(token(code))
+	return m.Get(Token(code))
+}
+
+// Register adds a function type operator, the existing function with the same token is replaced.
+// The token should be an identifier, e.g. `vat` or `clamp2`.
+func (m *Manager) Register(op ExecutableOperator) error {
+	if op.Type() != Function {
+		return fmt.Errorf("calc/operator: only function type operator could be registered, code: %s", op.Token())
+	}
+	if !isIdentifier(op.Token().String()) {
+		return fmt.Errorf("calc/operator: invalid function name: %s", op.Token())
+	}
+	if min, max := op.Arity(); min < 0 || (max != Variadic && max < min) {
+		return fmt.Errorf("calc/operator: invalid arity for code: %s, min: %d, max: %d", op.Token(), min, max)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.m[op.Token()]; ok && old.Type() != Function {
+		return fmt.Errorf("calc/operator: conflict with existing operator: %s", op.Token())
+	}
+	m.m[op.Token()] = op
+	return nil
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+
+	return s != ""
 }
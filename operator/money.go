@@ -0,0 +1,250 @@
+package operator
+
+// The money values are exact decimal amounts of currencies, e.g. `100 USD + 50.5 USD`. The currencies could not be
+// mixed without conversion, `in` converts the amounts by the RateProvider of the context, e.g. `100 USD in EUR`.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/xwjdsh/calc/decimal"
+)
+
+var (
+	// ErrNoRateProvider means the money is converted but the RateProvider is not set.
+	ErrNoRateProvider = errors.New("calc/operator: no exchange rate provider")
+	// ErrUnknownRate means the exchange rate of currencies is not found.
+	ErrUnknownRate = errors.New("calc/operator: unknown exchange rate")
+)
+
+// currencies are the ISO 4217 codes of the supported currencies.
+var currencies = map[string]bool{
+	"AED": true, "ARS": true, "AUD": true, "BGN": true, "BHD": true, "BRL": true, "CAD": true, "CHF": true,
+	"CLP": true, "CNY": true, "COP": true, "CZK": true, "DKK": true, "EGP": true, "EUR": true, "GBP": true,
+	"HKD": true, "HUF": true, "IDR": true, "ILS": true, "INR": true, "ISK": true, "JPY": true, "KRW": true,
+	"KWD": true, "MXN": true, "MYR": true, "NGN": true, "NOK": true, "NZD": true, "PHP": true, "PKR": true,
+	"PLN": true, "RON": true, "RUB": true, "SAR": true, "SEK": true, "SGD": true, "THB": true, "TRY": true,
+	"TWD": true, "UAH": true, "USD": true, "VND": true, "ZAR": true,
+}
+
+// IsCurrency reports whether the code is a supported ISO 4217 currency code, e.g. `USD`.
+func IsCurrency(code string) bool {
+	return currencies[code]
+}
+
+// Money is an amount of currency, e.g. `100 USD`, Currency is the ISO 4217 code.
+type Money struct {
+	Amount   decimal.Decimal
+	Currency string
+}
+
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
+
+// CurrencyError means the money of different currencies are mixed without conversion, e.g. `1 USD + 1 EUR`.
+type CurrencyError struct {
+	Op   Token
+	X, Y string
+}
+
+func (e *CurrencyError) Error() string {
+	return fmt.Sprintf("calc/operator: mixed currencies for code: %s, %s and %s, convert them by `in`", e.Op, e.X, e.Y)
+}
+
+// RateProvider provides the exchange rates of currencies, it should be safe for concurrent use.
+type RateProvider interface {
+	// Rate returns the amount of currency to for one unit of currency from, e.g. 0.92 for USD to EUR.
+	Rate(from, to string) (decimal.Decimal, error)
+}
+
+// StaticRates is the RateProvider of fixed rates, Rates are the amounts of currencies for one unit of Base,
+// the rate between two other currencies is derived from them.
+type StaticRates struct {
+	Base  string
+	Rates map[string]decimal.Decimal
+}
+
+// LoadStaticRates reads the rates in JSON format, e.g. `{"base": "USD", "rates": {"EUR": 0.92, "GBP": 0.79}}`.
+func LoadStaticRates(r io.Reader) (*StaticRates, error) {
+	var v struct {
+		Base  string                 `json:"base"`
+		Rates map[string]json.Number `json:"rates"`
+	}
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("calc/operator: invalid rates: %v", err)
+	}
+
+	rates := &StaticRates{Base: v.Base, Rates: map[string]decimal.Decimal{}}
+	for code, n := range v.Rates {
+		rate, err := decimal.Parse(n.String())
+		if err != nil || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("calc/operator: invalid rate of %s: %s", code, n)
+		}
+		rates.Rates[code] = rate
+	}
+
+	return rates, nil
+}
+
+func (r *StaticRates) rate(code string) (decimal.Decimal, bool) {
+	if code == r.Base {
+		return decimal.New(1, 0), true
+	}
+
+	rate, ok := r.Rates[code]
+	return rate, ok && rate.Sign() > 0
+}
+
+// Rate returns the amount of currency to for one unit of currency from, the derived rates are rounded
+// to DefaultDecimalPrecision digits.
+func (r *StaticRates) Rate(from, to string) (decimal.Decimal, error) {
+	f, ok1 := r.rate(from)
+	t, ok2 := r.rate(to)
+	if !ok1 || !ok2 {
+		return decimal.Decimal{}, fmt.Errorf("%w: %s to %s", ErrUnknownRate, from, to)
+	}
+	switch {
+	case from == to:
+		return decimal.New(1, 0), nil
+	case from == r.Base:
+		return t, nil
+	}
+
+	return t.Quo(f, DefaultDecimalPrecision, decimal.HalfEven)
+}
+
+// toDecimal converts number v to decimal, the rational number is rounded by the context if it is not terminating.
+func (c *Context) toDecimal(v interface{}) (decimal.Decimal, bool) {
+	if i, ok := toBigInt(v); ok {
+		return decimal.NewFromBigInt(i, 0), true
+	}
+
+	switch n := v.(type) {
+	case decimal.Decimal:
+		return n, true
+	case float64:
+		d, err := decimal.NewFromFloat(n)
+		return d, err == nil
+	case *big.Rat:
+		scale, ok := decimalScale(n.Denom())
+		if !ok {
+			scale = c.DecimalPrecision
+		}
+		d, _ := decimal.NewFromBigInt(n.Num(), 0).Quo(decimal.NewFromBigInt(n.Denom(), 0), scale, c.Rounding)
+		return d, true
+	}
+
+	return decimal.Decimal{}, false
+}
+
+// fromDecimal converts decimal d to the number type of the context.
+func (c *Context) fromDecimal(d decimal.Decimal) interface{} {
+	switch c.Numbers {
+	case DecimalNumbers:
+		return d
+	case RationalNumbers:
+		return d.Rat()
+	case IntegerNumbers:
+		if d.IsInteger() {
+			return normalizeInt(d.Int())
+		}
+	}
+
+	return d.Float64()
+}
+
+// moneyArithmetic applies the arithmetic operator t on money, or money and a number, ok is false if they are unsupported.
+// The money of different currencies could not be added, subtracted or divided.
+func (c *Context) moneyArithmetic(t Token, a, b interface{}) (interface{}, bool, error) {
+	ma, okA := a.(Money)
+	mb, okB := b.(Money)
+	switch {
+	case okA && okB:
+		if t != ADD && t != SUB && t != QUO {
+			break
+		}
+		if ma.Currency != mb.Currency {
+			return nil, true, &CurrencyError{Op: t, X: ma.Currency, Y: mb.Currency}
+		}
+
+		switch t {
+		case ADD:
+			return Money{Amount: ma.Amount.Add(mb.Amount), Currency: ma.Currency}, true, nil
+		case SUB:
+			return Money{Amount: ma.Amount.Sub(mb.Amount), Currency: ma.Currency}, true, nil
+		}
+
+		// the ratio of amounts is a number, e.g. `30 USD / 20 USD` is 1.5
+		if mb.Amount.Sign() == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		r, err := ma.Amount.Quo(mb.Amount, c.DecimalPrecision, c.Rounding)
+		return c.fromDecimal(r), true, err
+	case okA:
+		n, ok := c.toDecimal(b)
+		if !ok {
+			break
+		}
+
+		switch t {
+		case MUL:
+			return Money{Amount: ma.Amount.Mul(n), Currency: ma.Currency}, true, nil
+		case QUO:
+			if n.Sign() == 0 {
+				return nil, true, ErrDivisionByZero
+			}
+			r, err := ma.Amount.Quo(n, c.DecimalPrecision, c.Rounding)
+			return Money{Amount: r, Currency: ma.Currency}, true, err
+		}
+	case okB:
+		if n, ok := c.toDecimal(a); ok && t == MUL {
+			return Money{Amount: n.Mul(mb.Amount), Currency: mb.Currency}, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// compareMoney returns -1, 0 or 1 when money a is less than, equal to or greater than b of the same currency,
+// ok is false if any of them is not money.
+func compareMoney(t Token, a, b interface{}) (int, bool, error) {
+	ma, okA := a.(Money)
+	mb, okB := b.(Money)
+	if !okA || !okB {
+		return 0, false, nil
+	}
+	if ma.Currency != mb.Currency {
+		return 0, true, &CurrencyError{Op: t, X: ma.Currency, Y: mb.Currency}
+	}
+
+	return ma.Amount.Cmp(mb.Amount), true, nil
+}
+
+// exchange converts money a to the currency of b by the rate provider, it is the handler of `a in EUR`,
+// b should be one unit of the currency.
+func (c *Context) exchange(a interface{}, b Money) (interface{}, error) {
+	ma, ok := a.(Money)
+	if !ok || b.Amount.Cmp(decimal.New(1, 0)) != 0 {
+		return nil, ErrInvalidArguments
+	}
+	if ma.Currency == b.Currency {
+		return ma, nil
+	}
+	if c.Rates == nil {
+		return nil, ErrNoRateProvider
+	}
+
+	rate, err := c.Rates.Rate(ma.Currency, b.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	// the trailing zeros of rate are removed, e.g. `100 USD` is `80 EUR` by rate 0.80
+	return Money{Amount: ma.Amount.Mul(rate).Trim(ma.Amount.Scale()), Currency: b.Currency}, nil
+}
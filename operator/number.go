@@ -0,0 +1,674 @@
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xwjdsh/calc/decimal"
+)
+
+// NumberType is the type of number literals and variables.
+type NumberType int
+
+const (
+	// FloatNumbers are float64.
+	FloatNumbers NumberType = iota
+	// DecimalNumbers are decimal.Decimal.
+	DecimalNumbers
+	// RationalNumbers are *big.Rat.
+	RationalNumbers
+	// IntegerNumbers are int64 or *big.Int for integers, and float64 for the others.
+	IntegerNumbers
+)
+
+// Parse parses the number literal, e.g. `1.5`, the imaginary literal like `2i` is complex128 of all types.
+// The hexadecimal, binary and octal integers like `0xFF`, `0b1010` and `0o17` are int64 or *big.Int of all types,
+// so the bit masks are exact.
+func (t NumberType) Parse(literal string) (interface{}, error) {
+	if strings.HasSuffix(literal, "i") {
+		return strconv.ParseComplex(literal, 128)
+	}
+	if isBaseLiteral(literal) && !strings.ContainsAny(literal, ".pP") {
+		i, ok := new(big.Int).SetString(literal, 0)
+		if !ok {
+			return nil, fmt.Errorf("calc/operator: invalid number: %s", literal)
+		}
+		return normalizeInt(i), nil
+	}
+
+	switch t {
+	case DecimalNumbers:
+		return decimal.Parse(literal)
+	case RationalNumbers:
+		r, ok := new(big.Rat).SetString(literal)
+		if !ok {
+			return nil, fmt.Errorf("calc/operator: invalid number: %s", literal)
+		}
+		return r, nil
+	case IntegerNumbers:
+		if !strings.ContainsAny(literal, ".eE") {
+			return parseInt(literal)
+		}
+	}
+
+	return strconv.ParseFloat(literal, 64)
+}
+
+// parseInt parses the integer literal as int64, or *big.Int if it overflows int64.
+func parseInt(literal string) (interface{}, error) {
+	i, err := strconv.ParseInt(literal, 10, 64)
+	if err == nil {
+		return i, nil
+	}
+
+	b, ok := new(big.Int).SetString(literal, 10)
+	if !ok {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// fromInt returns the integer i of the number type.
+func (t NumberType) fromInt(i int64) interface{} {
+	switch t {
+	case DecimalNumbers:
+		return decimal.New(i, 0)
+	case RationalNumbers:
+		return big.NewRat(i, 1)
+	case IntegerNumbers:
+		return i
+	}
+
+	return float64(i)
+}
+
+// numberKind is the kind of number values, the bigger kind is used when two kinds are mixed,
+// e.g. decimal + float64 is float64, since the result is inexact anyway.
+type numberKind int
+
+const (
+	notNumber numberKind = iota
+	// int64 or *big.Int, the *big.Int is only used if the value overflows int64
+	intKind
+	decimalKind
+	ratKind
+	floatKind
+	complexKind
+)
+
+func kindOf(v interface{}) numberKind {
+	switch v.(type) {
+	case int64, *big.Int:
+		return intKind
+	case decimal.Decimal:
+		return decimalKind
+	case *big.Rat:
+		return ratKind
+	case float64:
+		return floatKind
+	case complex128:
+		return complexKind
+	}
+
+	return notNumber
+}
+
+// isNumber reports whether v is a number value.
+func isNumber(v interface{}) bool {
+	return kindOf(v) != notNumber
+}
+
+// convert converts number v to kind k, k should not be less than the kind of v.
+func convert(v interface{}, k numberKind) interface{} {
+	switch k {
+	case decimalKind:
+		if i, ok := toBigInt(v); ok {
+			return decimal.NewFromBigInt(i, 0)
+		}
+	case ratKind:
+		if d, ok := v.(decimal.Decimal); ok {
+			return d.Rat()
+		}
+		if i, ok := toBigInt(v); ok {
+			return new(big.Rat).SetInt(i)
+		}
+	case floatKind:
+		f, _ := toFloat(v)
+		return f
+	case complexKind:
+		if f, ok := toFloat(v); ok {
+			return complex(f, 0)
+		}
+	}
+
+	return v
+}
+
+// unify converts numbers a and b to the same kind, the kind is notNumber if any of them is not number.
+func unify(a, b interface{}) (interface{}, interface{}, numberKind) {
+	k1, k2 := kindOf(a), kindOf(b)
+	if k1 == notNumber || k2 == notNumber {
+		return a, b, notNumber
+	}
+
+	k := k1
+	if k2 > k {
+		k = k2
+	}
+
+	return convert(a, k), convert(b, k), k
+}
+
+// toBigInt returns the integer v as *big.Int, the result should not be modified.
+func toBigInt(v interface{}) (*big.Int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return big.NewInt(n), true
+	case *big.Int:
+		return n, true
+	}
+
+	return nil, false
+}
+
+// normalizeInt returns int64 if i fits in it.
+func normalizeInt(i *big.Int) interface{} {
+	if i.IsInt64() {
+		return i.Int64()
+	}
+
+	return i
+}
+
+// toFloat returns the nearest float64 of real number v.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	case decimal.Decimal:
+		return n.Float64(), true
+	case *big.Rat:
+		f, _ := n.Float64()
+		return f, true
+	}
+
+	return 0, false
+}
+
+// toInt returns the value of number v if it is an integer.
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case *big.Int:
+		if n.IsInt64() {
+			return n.Int64(), true
+		}
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt64 {
+			return int64(n), true
+		}
+	case decimal.Decimal:
+		if i := n.Int(); n.IsInteger() && i.IsInt64() {
+			return i.Int64(), true
+		}
+	case *big.Rat:
+		if n.IsInt() && n.Num().IsInt64() {
+			return n.Num().Int64(), true
+		}
+	}
+
+	return 0, false
+}
+
+// fromFloat converts the float64 result of math function to the kind of the argument,
+// the decimal result is rounded by the context, the rational result is kept as float64 since it is inexact.
+// NaN or infinity is ErrNotFinite in all kinds, e.g. `ln(0)`.
+func (c *Context) fromFloat(f float64, k numberKind) (interface{}, error) {
+	if k != decimalKind {
+		return f, nil
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("%w: %v", ErrNotFinite, f)
+	}
+
+	d, err := decimal.NewFromFloat(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+
+	return d.Round(c.DecimalPrecision, c.Rounding), nil
+}
+
+// fromRat converts the exact rational r to the number type of the context, e.g. the factors of units,
+// the decimal is rounded by the context if r is not a terminating decimal.
+func (c *Context) fromRat(r *big.Rat) interface{} {
+	switch c.Numbers {
+	case RationalNumbers:
+		return new(big.Rat).Set(r)
+	case DecimalNumbers:
+		scale, ok := decimalScale(r.Denom())
+		if !ok {
+			scale = c.DecimalPrecision
+		}
+		d, _ := decimal.NewFromBigInt(r.Num(), 0).Quo(decimal.NewFromBigInt(r.Denom(), 0), scale, c.Rounding)
+		return d
+	case IntegerNumbers:
+		if r.IsInt() {
+			return normalizeInt(r.Num())
+		}
+	}
+
+	f, _ := r.Float64()
+	return f
+}
+
+// decimalScale returns the digits count after the decimal point of 1/den, ok is false if it is not terminating.
+func decimalScale(den *big.Int) (int32, bool) {
+	d := new(big.Int).Set(den)
+	var twos, fives int32
+	for _, p := range []struct {
+		n     int64
+		count *int32
+	}{{2, &twos}, {5, &fives}} {
+		m, n := new(big.Int), big.NewInt(p.n)
+		for {
+			q, r := new(big.Int).QuoRem(d, n, m)
+			if r.Sign() != 0 {
+				break
+			}
+			d = q
+			*p.count++
+		}
+	}
+
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+// arithmetic applies the arithmetic operator t on numbers a and b, ok is false if they are not numbers.
+func (c *Context) arithmetic(t Token, a, b interface{}) (r interface{}, ok bool, err error) {
+	a, b, k := unify(a, b)
+	if k == notNumber {
+		return nil, false, nil
+	}
+
+	switch k {
+	case intKind:
+		i1, _ := toBigInt(a)
+		i2, _ := toBigInt(b)
+		r, ok, err = c.intArithmetic(t, i1, i2)
+	case decimalKind:
+		r, ok, err = c.decimalArithmetic(t, a.(decimal.Decimal), b.(decimal.Decimal))
+	case ratKind:
+		r, ok, err = ratArithmetic(t, a.(*big.Rat), b.(*big.Rat))
+	case floatKind:
+		r, ok, err = floatArithmetic(t, a.(float64), b.(float64))
+	case complexKind:
+		r, ok, err = complexArithmetic(t, a.(complex128), b.(complex128))
+	}
+
+	return r, ok, err
+}
+
+func (c *Context) intArithmetic(t Token, i1, i2 *big.Int) (interface{}, bool, error) {
+	switch t {
+	case ADD:
+		return normalizeInt(new(big.Int).Add(i1, i2)), true, nil
+	case SUB:
+		return normalizeInt(new(big.Int).Sub(i1, i2)), true, nil
+	case MUL:
+		return normalizeInt(new(big.Int).Mul(i1, i2)), true, nil
+	case QUO:
+		if i2.Sign() == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		q, r := new(big.Int).QuoRem(i1, i2, new(big.Int))
+		switch c.Division {
+		case TruncatedDivision:
+		case FloorDivision:
+			if r.Sign() != 0 && r.Sign() != i2.Sign() {
+				q.Sub(q, big.NewInt(1))
+			}
+		default:
+			if r.Sign() != 0 {
+				f, _ := new(big.Rat).SetFrac(i1, i2).Float64()
+				return f, true, nil
+			}
+		}
+		return normalizeInt(q), true, nil
+	case REM:
+		if i2.Sign() == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		return normalizeInt(new(big.Int).Rem(i1, i2)), true, nil
+	case CARET, DSTAR:
+		if i1.Sign() == 0 && i2.Sign() < 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		n, ok := toInt(i2)
+		if !ok || n < 0 || powTooLarge(i1.BitLen(), n) {
+			// the negative exponent is inexact, and the huge result is approximated
+			f1, _ := toFloat(i1)
+			f2, _ := toFloat(i2)
+			return math.Pow(f1, f2), true, nil
+		}
+		return normalizeInt(new(big.Int).Exp(i1, big.NewInt(n), nil)), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// maxIntPowBits is the maximum bits count of the exact integer power.
+const maxIntPowBits = 1 << 16
+
+// powTooLarge reports whether the exact power of an integer with the bits count to n exceeds maxIntPowBits,
+// the product is compared by division since it may overflow int64.
+func powTooLarge(bits int, n int64) bool {
+	if n < 0 {
+		n = -n
+	}
+
+	return n < 0 || (bits > 1 && n > maxIntPowBits/int64(bits))
+}
+
+func floatArithmetic(t Token, f1, f2 float64) (interface{}, bool, error) {
+	switch t {
+	case ADD:
+		return f1 + f2, true, nil
+	case SUB:
+		return f1 - f2, true, nil
+	case MUL:
+		return f1 * f2, true, nil
+	case QUO:
+		if f2 == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		return f1 / f2, true, nil
+	case REM:
+		// only integers are supported, since float remainder is inexact
+		i1, ok1 := toInt(f1)
+		i2, ok2 := toInt(f2)
+		if !ok1 || !ok2 {
+			return nil, false, nil
+		}
+		if i2 == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		return float64(i1 % i2), true, nil
+	case CARET, DSTAR:
+		if f1 == 0 && f2 < 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		return math.Pow(f1, f2), true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (c *Context) decimalArithmetic(t Token, d1, d2 decimal.Decimal) (interface{}, bool, error) {
+	var (
+		r   decimal.Decimal
+		err error
+	)
+	switch t {
+	case ADD:
+		r = d1.Add(d2)
+	case SUB:
+		r = d1.Sub(d2)
+	case MUL:
+		r = d1.Mul(d2)
+	case QUO:
+		r, err = d1.Quo(d2, c.DecimalPrecision, c.Rounding)
+	case REM:
+		r, err = d1.Rem(d2)
+	case CARET, DSTAR:
+		n, ok := toInt(d2)
+		if d1.Sign() == 0 && d2.Sign() < 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		if ok {
+			r, err = d1.Pow(n, c.DecimalPrecision, c.Rounding)
+		}
+		if !ok || errors.Is(err, decimal.ErrOverflow) {
+			// the fractional exponent is inexact, and the huge result is approximated
+			f := math.Pow(d1.Float64(), d2.Float64())
+			if math.IsInf(f, 0) {
+				return nil, true, ErrNotFinite
+			}
+			v, err := c.fromFloat(f, decimalKind)
+			return v, true, err
+		}
+	default:
+		return nil, false, nil
+	}
+
+	if err == decimal.ErrDivisionByZero {
+		return nil, true, ErrDivisionByZero
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+
+	return r, true, nil
+}
+
+func ratArithmetic(t Token, r1, r2 *big.Rat) (interface{}, bool, error) {
+	switch t {
+	case ADD:
+		return new(big.Rat).Add(r1, r2), true, nil
+	case SUB:
+		return new(big.Rat).Sub(r1, r2), true, nil
+	case MUL:
+		return new(big.Rat).Mul(r1, r2), true, nil
+	case QUO:
+		if r2.Sign() == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		return new(big.Rat).Quo(r1, r2), true, nil
+	case REM:
+		if r2.Sign() == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		// r1 - r2*trunc(r1/r2), the result has the sign of r1
+		q := new(big.Rat).Quo(r1, r2)
+		n := new(big.Int).Quo(q.Num(), q.Denom())
+		return new(big.Rat).Sub(r1, new(big.Rat).Mul(r2, new(big.Rat).SetInt(n))), true, nil
+	case CARET, DSTAR:
+		n, ok := toInt(r2)
+		if r1.Sign() == 0 && r2.Sign() < 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		bits := r1.Num().BitLen()
+		if b := r1.Denom().BitLen(); b > bits {
+			bits = b
+		}
+		if !ok || powTooLarge(bits, n) {
+			// the fractional exponent is inexact, and the huge result is approximated
+			f1, _ := r1.Float64()
+			f2, _ := r2.Float64()
+			if f := math.Pow(f1, f2); !math.IsInf(f, 0) {
+				return f, true, nil
+			}
+			return nil, true, ErrNotFinite
+		}
+		return ratPow(r1, n), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// ratPow returns r^n exactly.
+func ratPow(r *big.Rat, n int64) *big.Rat {
+	num, den := new(big.Int).Set(r.Num()), new(big.Int).Set(r.Denom())
+	if n < 0 {
+		num, den, n = den, num, -n
+	}
+
+	e := big.NewInt(n)
+	return new(big.Rat).SetFrac(num.Exp(num, e, nil), den.Exp(den, e, nil))
+}
+
+func complexArithmetic(t Token, c1, c2 complex128) (interface{}, bool, error) {
+	switch t {
+	case ADD:
+		return c1 + c2, true, nil
+	case SUB:
+		return c1 - c2, true, nil
+	case MUL:
+		return c1 * c2, true, nil
+	case QUO:
+		if c2 == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		return c1 / c2, true, nil
+	case CARET, DSTAR:
+		return cmplx.Pow(c1, c2), true, nil
+	}
+
+	// the remainder is undefined
+	return nil, false, nil
+}
+
+// equalNumbers reports whether number a equals to b, complex numbers could be compared only for equality.
+func equalNumbers(a, b interface{}) (bool, bool) {
+	a, b, k := unify(a, b)
+	if k == complexKind {
+		return a.(complex128) == b.(complex128), true
+	}
+
+	c, ok := compareNumbers(a, b)
+	return c == 0, ok
+}
+
+// compareNumbers returns -1, 0 or 1 when number a is less than, equal to or greater than b.
+func compareNumbers(a, b interface{}) (int, bool) {
+	a, b, k := unify(a, b)
+	switch k {
+	case intKind:
+		i1, _ := toBigInt(a)
+		i2, _ := toBigInt(b)
+		return i1.Cmp(i2), true
+	case decimalKind:
+		return a.(decimal.Decimal).Cmp(b.(decimal.Decimal)), true
+	case ratKind:
+		return a.(*big.Rat).Cmp(b.(*big.Rat)), true
+	case floatKind:
+		return cmpFloat(a.(float64), b.(float64)), true
+	}
+
+	return 0, false
+}
+
+// negate returns -v of number v.
+func negate(v interface{}) (interface{}, bool) {
+	if i, ok := toBigInt(v); ok {
+		return normalizeInt(new(big.Int).Neg(i)), true
+	}
+
+	switch n := v.(type) {
+	case float64:
+		return -n, true
+	case complex128:
+		return -n, true
+	case decimal.Decimal:
+		return n.Neg(), true
+	case *big.Rat:
+		return new(big.Rat).Neg(n), true
+	case time.Duration:
+		// `-days(1)` is a negative duration
+		return -n, true
+	case Quantity:
+		if v, ok := negate(n.Value); ok {
+			return Quantity{Value: v, Unit: n.Unit}, true
+		}
+	case Money:
+		return Money{Amount: n.Amount.Neg(), Currency: n.Currency}, true
+	}
+
+	return nil, false
+}
+
+// absolute returns |v| of number v, it is the modulus of complex number.
+func absolute(v interface{}) (interface{}, bool) {
+	if i, ok := toBigInt(v); ok {
+		return normalizeInt(new(big.Int).Abs(i)), true
+	}
+
+	switch n := v.(type) {
+	case float64:
+		return math.Abs(n), true
+	case complex128:
+		return cmplx.Abs(n), true
+	case decimal.Decimal:
+		return n.Abs(), true
+	case *big.Rat:
+		return new(big.Rat).Abs(n), true
+	}
+
+	return nil, false
+}
+
+// conjugate returns the complex conjugate of number v, it is v itself if v is real.
+func conjugate(v interface{}) (interface{}, bool) {
+	if c, ok := v.(complex128); ok {
+		return cmplx.Conj(c), true
+	}
+
+	return v, isNumber(v)
+}
+
+// sign returns -1, 0 or 1 of the same kind as real number v.
+func sign(v interface{}) (interface{}, bool) {
+	var s int64
+	switch n := v.(type) {
+	case int64:
+		s = int64(cmpFloat(float64(n), 0))
+	case *big.Int:
+		s = int64(n.Sign())
+	case float64:
+		if math.IsNaN(n) {
+			return nil, false
+		}
+		s = int64(cmpFloat(n, 0))
+	case decimal.Decimal:
+		s = int64(n.Sign())
+	case *big.Rat:
+		s = int64(n.Sign())
+	default:
+		return nil, false
+	}
+
+	return convert(s, kindOf(v)), true
+}
+
+// isFinite reports whether v is not NaN or infinite, the values other than numbers and quantities are finite.
+func isFinite(v interface{}) bool {
+	switch n := v.(type) {
+	case float64:
+		return !math.IsNaN(n) && !math.IsInf(n, 0)
+	case complex128:
+		return !cmplx.IsNaN(n) && !cmplx.IsInf(n)
+	case Quantity:
+		return isFinite(n.Value)
+	}
+
+	return true
+}
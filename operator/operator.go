@@ -1,11 +1,11 @@
 // Package operator represents the operator in expression,
-// include three types, general, bracket and function.
+// include four types, general, unary, bracket and function.
 
 package operator
 
 import (
+	"errors"
 	"fmt"
-	"math"
 	"strings"
 )
 
@@ -16,45 +16,191 @@ const (
 	General Type = iota
 	Bracket
 	Function
+	// Unary is the prefix operator with one operand.
+	Unary
 )
 
-type token string
+var (
+	// ErrInvalidArguments means the argument types are unsupported by the operator.
+	ErrInvalidArguments = errors.New("calc/operator: invalid arguments")
+	// ErrArgsCount means the arguments count mismatches the operator arity.
+	ErrArgsCount = errors.New("calc/operator: invalid param count")
+	// ErrDivisionByZero means the divisor is zero.
+	ErrDivisionByZero = errors.New("calc/operator: division by zero")
+	// ErrNotFinite means the function result is NaN or infinite, e.g. `ln(0)`.
+	ErrNotFinite = errors.New("calc/operator: result is not finite")
+	// ErrIncompatibleUnits means the units of quantities have different dimensions, e.g. `1 m + 1 s`.
+	ErrIncompatibleUnits = errors.New("calc/operator: incompatible units")
+	// ErrNotConverged means the iteration of solving equation does not converge, e.g. `irr(1, 2)`.
+	ErrNotConverged = errors.New("calc/operator: iteration does not converge")
+)
+
+// Associativity defines how operators of the same preference are grouped.
+type Associativity int
+
+const (
+	// LeftAssociative operators are grouped from the left, e.g. `1-2-3` equals to `(1-2)-3`.
+	LeftAssociative Associativity = iota
+	// RightAssociative operators are grouped from the right, e.g. `2^3^2` equals to `2^(3^2)`.
+	RightAssociative
+)
 
-func (t token) String() string {
+// Token is the code of operator in expressions.
+type Token string
+
+// token is embedded by operators, so they have the Token and String methods.
+type token = Token
+
+func (t Token) String() string {
 	return string(t)
 }
 
-func (t token) Token() token {
+// Token returns itself.
+func (t Token) Token() Token {
 	return t
 }
 
 const (
 	// general type
-	ADD   token = "+"
-	SUB   token = "-"
-	MUL   token = "*"
-	QUO   token = "/"
-	REM   token = "%"
-	COMMA token = ","
+	ADD   Token = "+"
+	SUB   Token = "-"
+	MUL   Token = "*"
+	QUO   Token = "/"
+	REM   Token = "%"
+	COMMA Token = ","
+	EQL   Token = "=="
+	NEQ   Token = "!="
+	LSS   Token = "<"
+	LEQ   Token = "<="
+	GTR   Token = ">"
+	GEQ   Token = ">="
+	LAND  Token = "&&"
+	LOR   Token = "||"
+	CARET Token = "^"  // exponent, or bitwise exclusive or if Context.CaretXor is set
+	DSTAR Token = "**" // exponent
+	MATCH Token = "=~" // regular expression matching
+	TO    Token = "to" // unit conversion, e.g. `60 mph to m/s`
+	IN    Token = "in" // same as TO
+	AND   Token = "&"  // bitwise and
+	OR    Token = "|"  // bitwise or
+	SHL   Token = "<<" // shift left
+	SHR   Token = ">>" // arithmetic shift right
+
+	// unary type
+	NOT   Token = "!"
+	TILDE Token = "~" // bitwise not
 
 	// bracket type
-	LPAREN token = "("
-	RPAREN token = ")"
+	LPAREN Token = "("
+	RPAREN Token = ")"
 
 	// function type
-	SIN token = "sin"
-	COS token = "cos"
-	TAN token = "tan"
-	ABS token = "abs"
-	OPP token = "opp" // opposite number
-	SUM token = "sum"
-	MAX token = "max"
-	MIN token = "min"
-	POW token = "pow"
+	SIN Token = "sin"
+	COS Token = "cos"
+	TAN Token = "tan"
+	ABS Token = "abs"
+	OPP Token = "opp" // opposite number
+	SUM Token = "sum"
+	MAX Token = "max"
+	MIN Token = "min"
+	POW Token = "pow"
+
+	// complex functions, they accept real numbers too
+	SQRT Token = "sqrt"
+	EXP  Token = "exp"
+	CONJ Token = "conj"
+	ARG  Token = "arg"
+	RE   Token = "re"
+	IM   Token = "im"
+
+	CBRT  Token = "cbrt"
+	LN    Token = "ln"
+	LOG   Token = "log"
+	LOG2  Token = "log2"
+	LOG10 Token = "log10"
+	ASIN  Token = "asin"
+	ACOS  Token = "acos"
+	ATAN  Token = "atan"
+	ATAN2 Token = "atan2"
+	SINH  Token = "sinh"
+	COSH  Token = "cosh"
+	TANH  Token = "tanh"
+	FLOOR Token = "floor"
+	CEIL  Token = "ceil"
+	ROUND Token = "round"
+	TRUNC Token = "trunc"
+	HYPOT Token = "hypot"
+	MOD   Token = "mod"
+	SIGN  Token = "sign"
+
+	// angle conversion functions, they are independent of the angle unit
+	DEG Token = "deg"
+	RAD Token = "rad"
+
+	// statistics functions, variance and stddev are of sample, pvariance and pstddev are of population
+	AVG        Token = "avg"
+	MEAN       Token = "mean"
+	MEDIAN     Token = "median"
+	MODE       Token = "mode"
+	VARIANCE   Token = "variance"
+	PVARIANCE  Token = "pvariance"
+	STDDEV     Token = "stddev"
+	PSTDDEV    Token = "pstddev"
+	PERCENTILE Token = "percentile"
+	COUNT      Token = "count"
+	PRODUCT    Token = "product"
+	RANGE      Token = "range"
+	GEOMEAN    Token = "geomean"
+
+	// financial functions
+	PMT    Token = "pmt"
+	PV     Token = "pv"
+	FV     Token = "fv"
+	NPER   Token = "nper"
+	RATE   Token = "rate"
+	NPV    Token = "npv"
+	IRR    Token = "irr"
+	ROUND2 Token = "round2"
+
+	// string functions
+	LEN        Token = "len"
+	UPPER      Token = "upper"
+	LOWER      Token = "lower"
+	TRIM       Token = "trim"
+	SUBSTR     Token = "substr"
+	REPLACE    Token = "replace"
+	CONTAINS   Token = "contains"
+	STARTSWITH Token = "startswith"
+	ENDSWITH   Token = "endswith"
+	SPLIT      Token = "split"
+	JOIN       Token = "join"
+	INDEXOF    Token = "indexof"
+	PAD        Token = "pad"
+	FORMAT     Token = "format"
+	STR        Token = "str"
+	NUM        Token = "num"
+
+	// regular expression functions
+	MATCHES       Token = "matches"
+	REGEX_EXTRACT Token = "regex_extract"
+	REGEX_REPLACE Token = "regex_replace"
+
+	// time functions, the duration functions convert numbers to durations and vice versa
+	NOW         Token = "now"
+	DATE        Token = "date"
+	SECONDS     Token = "seconds"
+	MINUTES     Token = "minutes"
+	HOURS       Token = "hours"
+	DAYS        Token = "days"
+	WEEKS       Token = "weeks"
+	WEEKDAY     Token = "weekday"
+	ADDMONTHS   Token = "addmonths"
+	FORMAT_DATE Token = "format_date"
 )
 
 var (
 	_ Operator = new(generalOperator)
+	_ Operator = new(unaryOperator)
 	_ Operator = new(bracketOperator)
 	_ Operator = new(functionOperator)
 )
@@ -62,252 +208,313 @@ var (
 // Operator abstract the methods to fetch operator basic info.
 type Operator interface {
 	// Token is the operator token.
-	Token() token
+	Token() Token
 	// Type operator type.
 	Type() Type
 	// Preference represent the operator priority, the bigger the value, the higher the priority.
 	Preference() int
+	// Associativity represent how the operators with same preference are grouped.
+	Associativity() Associativity
 }
 
 var (
 	_ ExecutableOperator = new(generalOperator)
+	_ ExecutableOperator = new(unaryOperator)
 	_ ExecutableOperator = new(functionOperator)
 )
 
+// Variadic is the maximum arguments count of operators accepting unlimited arguments.
+const Variadic = -1
+
 // ExecutableOperator abstract the methods to execute operator.
 type ExecutableOperator interface {
 	Operator
-	// ArgsCount returns the required arguments count.
-	ArgsCount() int
+	// Arity returns the minimum and maximum arguments count, the maximum is Variadic if unlimited.
+	Arity() (min, max int)
 	// Execute the operator handler
 	Execute(args []interface{}) (interface{}, error)
 }
 
 type generalOperator struct {
 	token
+	ctx *Context
 }
 
 func newGeneralOperator(t token) *generalOperator {
-	return &generalOperator{token: t}
+	return &generalOperator{token: t, ctx: defaultContext}
 }
 
 func (o *generalOperator) Type() Type {
 	return General
 }
 
-func (o *generalOperator) ArgsCount() int {
-	return 2
+func (o *generalOperator) Arity() (int, int) {
+	return 2, 2
 }
 
+// Preference follows C for the bitwise operators, they are lower than comparison, e.g. `$a & 1 == 1` equals to
+// `$a & (1 == 1)`, and the shifts are lower than arithmetic operators.
 func (o *generalOperator) Preference() int {
 	switch o.token {
-	case ADD, SUB:
+	case LOR:
 		return 1
-	case MUL, QUO, REM:
+	case LAND:
 		return 2
+	case OR:
+		return 3
+	case AND:
+		return 5
+	case EQL, NEQ, MATCH:
+		return 6
+	case LSS, LEQ, GTR, GEQ:
+		return 7
+	case TO, IN:
+		// lower than arithmetic operators, `1 km + 1 m to m` equals to `(1 km + 1 m) to m`
+		return 8
+	case SHL, SHR:
+		return 9
+	case ADD, SUB:
+		return 10
+	case MUL, QUO, REM:
+		return 11
+	case CARET:
+		// the exclusive or is between `|` and `&`
+		if o.ctx.CaretXor {
+			return 4
+		}
+		return 13
+	case DSTAR:
+		// higher than unary operators, `-2^2` equals to `-(2^2)`
+		return 13
 	}
 
 	return 0
 }
 
+func (o *generalOperator) Associativity() Associativity {
+	if (o.token == CARET && !o.ctx.CaretXor) || o.token == DSTAR {
+		return RightAssociative
+	}
+
+	return LeftAssociative
+}
+
+// Execute applies the operator on args, the results of NaN or infinity are ErrNotFinite like the functions,
+// e.g. `1e308^2` or `1e308*10`.
 func (o *generalOperator) Execute(args []interface{}) (interface{}, error) {
+	r, err := o.execute(args)
+	if err == nil && !isFinite(r) {
+		return nil, fmt.Errorf("%w for code: %s, result: %v", ErrNotFinite, o.token, r)
+	}
+
+	return r, err
+}
+
+func (o *generalOperator) execute(args []interface{}) (interface{}, error) {
 	if len(args) != 2 {
-		return nil, fmt.Errorf("calc/operator: invalid param count for code: %s, expected: 2, actual: %d", o.token, len(args))
+		return nil, fmt.Errorf("%w for code: %s, expected: 2, actual: %d", ErrArgsCount, o.token, len(args))
 	}
 
-	// TODO could be simplification there
 	arg1, arg2 := args[0], args[1]
 	vs1, oks1 := arg1.(string)
 	vs2, oks2 := arg2.(string)
 
-	vf1, okf1 := arg1.(float64)
-	vf2, okf2 := arg2.(float64)
+	vb1, okb1 := arg1.(bool)
+	vb2, okb2 := arg2.(bool)
 
 	switch o.token {
-	case ADD:
-		if oks1 && oks2 {
-			return vs1 + vs2, nil
+	case AND, OR, SHL, SHR:
+		return bitwise(o.token, arg1, arg2)
+	case ADD, SUB, MUL, QUO, REM, CARET, DSTAR:
+		if o.token == CARET && o.ctx.CaretXor {
+			return bitwise(o.token, arg1, arg2)
 		}
-		if okf1 && okf2 {
-			return vf1 + vf2, nil
+		if r, ok, err := o.ctx.arithmetic(o.token, arg1, arg2); ok {
+			return r, err
 		}
-	case MUL:
-		if okf1 && okf2 {
-			return vf1 * vf2, nil
+		if r, ok, err := o.ctx.timeArithmetic(o.token, arg1, arg2); ok {
+			return r, err
 		}
-		if (oks1 && okf2) || (oks2 && okf1) {
-			s, f := vs2, vf1
-			if oks1 {
-				s, f = vs1, vf2
+		if r, ok, err := o.ctx.quantityArithmetic(o.token, arg1, arg2); ok {
+			return r, err
+		}
+		if r, ok, err := o.ctx.moneyArithmetic(o.token, arg1, arg2); ok {
+			return r, err
+		}
+
+		if o.token == ADD && oks1 && oks2 {
+			return vs1 + vs2, nil
+		}
+		if o.token == MUL && (oks1 || oks2) {
+			s, n := vs1, arg2
+			if oks2 {
+				s, n = vs2, arg1
 			}
-			i := int(f)
-			if f == float64(i) {
+			if i, ok := toInt(n); ok {
 				if i < 0 {
 					return "", nil
 				}
 
-				return strings.Repeat(s, i), nil
+				return strings.Repeat(s, int(i)), nil
 			}
 		}
-	case SUB:
-		if okf1 && okf2 {
-			return vf1 - vf2, nil
+	case EQL, NEQ:
+		if eq, ok := equalNumbers(arg1, arg2); ok {
+			return eq == (o.token == EQL), nil
+		}
+		if c, ok := compareTimes(arg1, arg2); ok {
+			return (c == 0) == (o.token == EQL), nil
 		}
-	case QUO:
-		if okf1 && okf2 {
-			if vf2 == 0 {
-				return nil, fmt.Errorf("calc/operator: division by zero")
+		if c, ok, err := o.ctx.compareQuantities(arg1, arg2); ok {
+			if err != nil {
+				return nil, err
 			}
-			return vf1 / vf2, nil
+			return (c == 0) == (o.token == EQL), nil
 		}
-	case REM:
-		if okf1 && okf2 {
-			i1, i2 := int(vf1), int(vf2)
-			if vf1 == float64(i1) && vf2 == float64(i2) {
-				return float64(i1 % i2), nil
+		if c, ok, err := compareMoney(o.token, arg1, arg2); ok {
+			if err != nil {
+				return nil, err
 			}
+			return (c == 0) == (o.token == EQL), nil
+		}
+		if (oks1 && oks2) || (okb1 && okb2) {
+			return (arg1 == arg2) == (o.token == EQL), nil
+		}
+	case MATCH:
+		if oks1 && oks2 {
+			return matchString(o.ctx, vs1, vs2)
+		}
+	case LSS, LEQ, GTR, GEQ:
+		if c, ok := compareNumbers(arg1, arg2); ok {
+			return compare(o.token, c), nil
+		}
+		if c, ok := compareTimes(arg1, arg2); ok {
+			return compare(o.token, c), nil
+		}
+		if c, ok, err := o.ctx.compareQuantities(arg1, arg2); ok {
+			if err != nil {
+				return nil, err
+			}
+			return compare(o.token, c), nil
+		}
+		if c, ok, err := compareMoney(o.token, arg1, arg2); ok {
+			if err != nil {
+				return nil, err
+			}
+			return compare(o.token, c), nil
+		}
+		if oks1 && oks2 {
+			return compare(o.token, strings.Compare(vs1, vs2)), nil
+		}
+	case TO, IN:
+		if m, ok := arg2.(Money); ok {
+			return o.ctx.exchange(arg1, m)
+		}
+		return o.ctx.to(arg1, arg2)
+	case LAND:
+		if okb1 && okb2 {
+			return vb1 && vb2, nil
+		}
+	case LOR:
+		if okb1 && okb2 {
+			return vb1 || vb2, nil
 		}
 	case COMMA:
-		if oks1 || okf1 {
+		vSli1, okSli1 := arg1.([]interface{})
+		if !okSli1 {
 			return []interface{}{arg1, arg2}, nil
 		}
-		vSli1, okSli1 := arg1.([]interface{})
-		if okSli1 && len(vSli1) > 0 {
-			return append(vSli1, arg2), nil
+		if len(vSli1) > 0 {
+			// copy on append, the list may be a variable
+			return append(vSli1[:len(vSli1):len(vSli1)], arg2), nil
 		}
 	}
 
-	return nil, fmt.Errorf("calc/operator: invalid arguments for code: %s", o.token)
+	return nil, fmt.Errorf("%w for code: %s", ErrInvalidArguments, o.token)
 }
 
-type bracketOperator struct {
-	token
+// compare converts the comparison result to bool, c is -1, 0 or 1.
+func compare(t Token, c int) bool {
+	switch t {
+	case LSS:
+		return c < 0
+	case LEQ:
+		return c <= 0
+	case GTR:
+		return c > 0
+	case GEQ:
+		return c >= 0
+	}
+
+	return false
 }
 
-func newBracketOperator(t token) *bracketOperator {
-	return &bracketOperator{token: t}
+func cmpFloat(f1, f2 float64) int {
+	switch {
+	case f1 < f2:
+		return -1
+	case f1 > f2:
+		return 1
+	}
+
+	return 0
 }
 
-func (o *bracketOperator) Type() Type {
-	return Bracket
+type unaryOperator struct {
+	token
 }
 
-func (o *bracketOperator) Preference() int {
-	return -1
+func newUnaryOperator(t Token) *unaryOperator {
+	return &unaryOperator{token: t}
 }
 
-type functionOperator struct {
-	token
+func (o *unaryOperator) Type() Type {
+	return Unary
 }
 
-func newFunctionOperator(t token) *functionOperator {
-	return &functionOperator{token: t}
+func (o *unaryOperator) Arity() (int, int) {
+	return 1, 1
 }
 
-func (o *functionOperator) Type() Type {
-	return Function
+func (o *unaryOperator) Preference() int {
+	return 12
 }
 
-func (o *functionOperator) ArgsCount() int {
-	return 1
+func (o *unaryOperator) Associativity() Associativity {
+	return RightAssociative
 }
 
-func (o *functionOperator) Execute(args []interface{}) (interface{}, error) {
+func (o *unaryOperator) Execute(args []interface{}) (interface{}, error) {
 	if len(args) != 1 {
-		return nil, fmt.Errorf("calc/operator: invalid param count for code: %s, expected: 1, actual: %d", o.token, len(args))
+		return nil, fmt.Errorf("%w for code: %s, expected: 1, actual: %d", ErrArgsCount, o.token, len(args))
 	}
 
-	arg1 := args[0]
-	vf1, okf1 := arg1.(float64)
-	originalFunc := func(f float64) float64 { return f }
-	floatOpMap := map[token]func(f float64) float64{
-		SIN: math.Sin,
-		COS: math.Cos,
-		TAN: math.Tan,
-		ABS: math.Abs,
-		OPP: func(f float64) float64 { return -f },
-		SUM: originalFunc,
-		MAX: originalFunc,
-		MIN: originalFunc,
+	if v, ok := args[0].(bool); ok && o.token == NOT {
+		return !v, nil
 	}
-
-	if okf1 && floatOpMap[o.token] != nil {
-		return floatOpMap[o.token](vf1), nil
+	if o.token == TILDE {
+		return bitwiseNot(args[0])
 	}
 
-	vSli1, okSli1 := arg1.([]interface{})
-	if okSli1 && len(vSli1) > 0 {
-		switch o.token {
-		case SUM:
-			if sum := 0.0; supposeFloatSlice(vSli1, func(_ int, f float64) { sum += f }) {
-				return sum, nil
-			}
-		case MAX, MIN:
-			eff := func(f1, f2 float64) bool { return f1 > f2 }
-			esf := func(s1, s2 string) bool { return s1 > s2 }
-			if o.token == MIN {
-				eff = func(f1, f2 float64) bool { return f1 < f2 }
-				esf = func(s1, s2 string) bool { return s1 < s2 }
-			}
-
-			if r := 0.0; supposeFloatSlice(vSli1, func(i int, f float64) {
-				if i == 0 || eff(f, r) {
-					r = f
-				}
-			}) {
-				return r, nil
-			}
-
-			if r := ""; supposeStringSlice(vSli1, func(i int, s string) {
-				if i == 0 || esf(s, r) {
-					r = s
-				}
-			}) {
-				return r, nil
-			}
-		case POW:
-			if len(vSli1) == 2 {
-				fs := make([]float64, 2)
-				if supposeFloatSlice(vSli1, func(i int, f float64) {
-					fs[i] = f
-				}) {
-					return math.Pow(fs[0], fs[1]), nil
-				}
-			}
-		}
-	}
-
-	return nil, fmt.Errorf("calc/operator: invalid arguments for code: %s", o.token)
+	return nil, fmt.Errorf("%w for code: %s", ErrInvalidArguments, o.token)
 }
 
-func (o *functionOperator) Preference() int {
-	if o.token == OPP {
-		return 2
-	}
-
-	return 0
+type bracketOperator struct {
+	token
 }
 
-func supposeFloatSlice(vs []interface{}, f func(i int, f float64)) bool {
-	for i, v := range vs {
-		tmp, ok := v.(float64)
-		if !ok {
-			return false
-		}
-		f(i, tmp)
-	}
+func newBracketOperator(t token) *bracketOperator {
+	return &bracketOperator{token: t}
+}
 
-	return true
+func (o *bracketOperator) Type() Type {
+	return Bracket
 }
 
-func supposeStringSlice(vs []interface{}, f func(i int, s string)) bool {
-	for i, v := range vs {
-		tmp, ok := v.(string)
-		if !ok {
-			return false
-		}
-		f(i, tmp)
-	}
+func (o *bracketOperator) Preference() int {
+	return -1
+}
 
-	return true
+func (o *bracketOperator) Associativity() Associativity {
+	return LeftAssociative
 }
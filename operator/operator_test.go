@@ -1,14 +1,19 @@
 package operator
 
 import (
+	"errors"
 	"math"
+	"math/big"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/xwjdsh/calc/decimal"
 )
 
 func TestGeneralOperator(t *testing.T) {
 	cases := []struct {
-		code      token
+		code      Token
 		args      []interface{}
 		expected  interface{}
 		expectErr bool
@@ -20,9 +25,37 @@ func TestGeneralOperator(t *testing.T) {
 		{code: REM, args: []interface{}{10.0, 3.0}, expected: 1.0},
 		{code: COMMA, args: []interface{}{1.0, 2.0}, expected: []interface{}{1.0, 2.0}},
 		{code: COMMA, args: []interface{}{[]interface{}{1.0, 2.0}, 3.0}, expected: []interface{}{1.0, 2.0, 3.0}},
+		{code: EQL, args: []interface{}{"a", "a"}, expected: true},
+		{code: NEQ, args: []interface{}{true, false}, expected: true},
+		{code: LSS, args: []interface{}{1.0, 2.0}, expected: true},
+		{code: GEQ, args: []interface{}{"a", "b"}, expected: false},
+		{code: LAND, args: []interface{}{true, false}, expected: false},
+		{code: LOR, args: []interface{}{true, false}, expected: true},
+		{code: CARET, args: []interface{}{2.0, 3.0}, expected: 8.0},
+		{code: DSTAR, args: []interface{}{4.0, 0.5}, expected: 2.0},
+		{code: ADD, args: []interface{}{decimal.New(1, 1), 0.5}, expected: 0.6},
+		{code: LSS, args: []interface{}{decimal.New(1, 1), 0.2}, expected: true},
+		{code: EQL, args: []interface{}{decimal.New(10, 1), decimal.New(1, 0)}, expected: true},
+		{code: MUL, args: []interface{}{"ab", decimal.New(2, 0)}, expected: "abab"},
+		{code: QUO, args: []interface{}{decimal.New(1, 0), decimal.New(0, 0)}, expectErr: true},
+		{code: LSS, args: []interface{}{big.NewRat(1, 3), decimal.New(3, 1)}, expected: false},
+		{code: ADD, args: []interface{}{big.NewRat(1, 2), 0.25}, expected: 0.75},
+		{code: REM, args: []interface{}{big.NewRat(1, 2), big.NewRat(0, 1)}, expectErr: true},
+		{code: ADD, args: []interface{}{int64(1), int64(2)}, expected: int64(3)},
+		{code: QUO, args: []interface{}{int64(6), int64(4)}, expected: 1.5},
+		{code: ADD, args: []interface{}{int64(1), 0.5}, expected: 1.5},
+		{code: GTR, args: []interface{}{int64(2), decimal.New(15, 1)}, expected: true},
+		{code: EQL, args: []interface{}{1.0, "1"}, expectErr: true},
+		{code: LOR, args: []interface{}{1.0, true}, expectErr: true},
 		{code: QUO, args: []interface{}{1.0, 0.0}, expectErr: true},
 		{code: ADD, args: []interface{}{1.0, 0.0, 1.0}, expectErr: true},
 		{code: REM, args: []interface{}{10.1, 3.0}, expectErr: true},
+		{code: AND, args: []interface{}{int64(12), int64(10)}, expected: int64(8)},
+		{code: OR, args: []interface{}{12.0, int64(3)}, expected: int64(15)},
+		{code: SHL, args: []interface{}{decimal.New(1, 0), int64(3)}, expected: int64(8)},
+		{code: AND, args: []interface{}{math.Pow(2, 60), 1.0}, expectErr: true},
+		{code: SHR, args: []interface{}{int64(-1), int64(10)}, expected: int64(-1)},
+		{code: AND, args: []interface{}{0.5, 1.0}, expectErr: true},
 	}
 
 	for _, c := range cases {
@@ -41,9 +74,38 @@ func TestGeneralOperator(t *testing.T) {
 	}
 }
 
+func TestUnaryOperator(t *testing.T) {
+	cases := []struct {
+		code      Token
+		args      []interface{}
+		expected  interface{}
+		expectErr bool
+	}{
+		{code: NOT, args: []interface{}{true}, expected: false},
+		{code: NOT, args: []interface{}{1.0}, expectErr: true},
+		{code: TILDE, args: []interface{}{int64(0)}, expected: int64(-1)},
+		{code: TILDE, args: []interface{}{true}, expectErr: true},
+	}
+
+	for _, c := range cases {
+		result, err := newUnaryOperator(c.code).Execute(c.args)
+		if c.expectErr && err == nil {
+			t.Errorf("expect error, got nil")
+		}
+
+		if !c.expectErr && err != nil {
+			t.Errorf("expect no error, got %v", err)
+		}
+
+		if !reflect.DeepEqual(c.expected, result) {
+			t.Errorf("expected: %v, got: %v", c.expected, result)
+		}
+	}
+}
+
 func TestFunctionOperator(t *testing.T) {
 	cases := []struct {
-		code      token
+		code      Token
 		args      []interface{}
 		expected  interface{}
 		expectErr bool
@@ -58,6 +120,12 @@ func TestFunctionOperator(t *testing.T) {
 		{code: MAX, args: []interface{}{[]interface{}{1.0, 2.0, 3.0}}, expected: 3.0},
 		{code: MIN, args: []interface{}{[]interface{}{1.0, 2.0, 3.0}}, expected: 1.0},
 		{code: POW, args: []interface{}{[]interface{}{2.0, 2.0}}, expected: 4.0},
+		{code: SQRT, args: []interface{}{-9.0}, expected: complex(0, 3)},
+		{code: CONJ, args: []interface{}{complex(1, 1)}, expected: complex(1, -1)},
+		{code: CONJ, args: []interface{}{1.0}, expected: 1.0},
+		{code: RE, args: []interface{}{complex(1, 2)}, expected: 1.0},
+		{code: IM, args: []interface{}{2.0}, expected: 0.0},
+		{code: IM, args: []interface{}{"a"}, expectErr: true},
 		{code: COS, args: []interface{}{10.0, 2.0}, expectErr: true},
 	}
 
@@ -76,3 +144,139 @@ func TestFunctionOperator(t *testing.T) {
 		}
 	}
 }
+
+func TestManagerRegister(t *testing.T) {
+	clamp := func(args ...interface{}) (interface{}, error) {
+		return math.Max(args[1].(float64), math.Min(args[0].(float64), args[2].(float64))), nil
+	}
+
+	cases := []struct {
+		op        ExecutableOperator
+		expectErr bool
+	}{
+		{op: NewFunction("Clamp", 3, 3, clamp)},
+		{op: NewFunction("max", 1, Variadic, clamp)},
+		{op: NewFunction("+", 2, 2, clamp), expectErr: true},
+		{op: NewFunction("1st", 1, 1, clamp), expectErr: true},
+		{op: NewFunction("bad", 2, 1, clamp), expectErr: true},
+		{op: newGeneralOperator(ADD), expectErr: true},
+	}
+
+	m := NewManager()
+	for _, c := range cases {
+		err := m.Register(c.op)
+		if c.expectErr && err == nil {
+			t.Errorf("expect error, got nil, code: %s", c.op.Token())
+		}
+
+		if !c.expectErr && err != nil {
+			t.Errorf("expect no error, got %v, code: %s", err, c.op.Token())
+		}
+	}
+
+	op, ok := m.GetByString("clamp")
+	if !ok {
+		t.Fatalf("expect registered function clamp")
+	}
+
+	result, err := op.(ExecutableOperator).Execute([]interface{}{5.0, 0.0, 3.0})
+	if err != nil || result != 3.0 {
+		t.Errorf("expected: 3, got: %v, %v", result, err)
+	}
+
+	if _, err := op.(ExecutableOperator).Execute([]interface{}{5.0, 0.0}); err == nil {
+		t.Errorf("expect error, got nil")
+	}
+}
+
+func TestContextRegexp(t *testing.T) {
+	ctx := NewContext()
+	re1, err := ctx.Regexp(`^\d+$`)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	if re2, _ := ctx.Regexp(`^\d+$`); re1 != re2 {
+		t.Errorf("expect the cached pattern, got a new one")
+	}
+
+	if _, err := ctx.Regexp(`(`); err == nil {
+		t.Errorf("expect error of invalid pattern, got nil")
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	cases := []struct {
+		unit      string
+		expected  string
+		expectErr bool
+	}{
+		{unit: "km", expected: "km"},
+		{unit: "kg*m/s^2", expected: "kg*m/s^2"},
+		{unit: "m * m / s / s", expected: "m^2/s^2"},
+		{unit: "1/s", expected: "1/s"},
+		{unit: "m^-1*s", expected: "s/m"},
+		{unit: "m/m", expected: "1"},
+		{unit: "furlong", expectErr: true},
+		{unit: "m^x", expectErr: true},
+		{unit: "m/", expectErr: true},
+		{unit: "degC/s", expectErr: true},
+	}
+
+	for _, c := range cases {
+		u, err := ParseUnit(c.unit)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("expect error, got nil, unit: %s", c.unit)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("expect no error, got %v, unit: %s", err, c.unit)
+			continue
+		}
+		if u.String() != c.expected {
+			t.Errorf("expected: %s, got: %s, unit: %s", c.expected, u.String(), c.unit)
+		}
+	}
+}
+
+func TestStaticRates(t *testing.T) {
+	rates, err := LoadStaticRates(strings.NewReader(`{"base": "USD", "rates": {"EUR": 0.8, "GBP": "0.75"}}`))
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	cases := []struct {
+		from, to  string
+		expected  string
+		expectErr bool
+	}{
+		{from: "USD", to: "EUR", expected: "0.8"},
+		{from: "EUR", to: "USD", expected: "1.25"},
+		{from: "EUR", to: "GBP", expected: "0.9375"},
+		{from: "GBP", to: "GBP", expected: "1"},
+		{from: "USD", to: "JPY", expectErr: true},
+	}
+
+	for _, c := range cases {
+		r, err := rates.Rate(c.from, c.to)
+		if c.expectErr {
+			if !errors.Is(err, ErrUnknownRate) {
+				t.Errorf("expect unknown rate error, got %v, %s to %s", err, c.from, c.to)
+			}
+			continue
+		}
+
+		if err != nil || r.String() != c.expected {
+			t.Errorf("expected: %s, got: %v, %v, %s to %s", c.expected, r, err, c.from, c.to)
+		}
+	}
+
+	for _, s := range []string{`{"base": "USD", "rates": {"EUR": -1}}`, `{"rates": {"EUR": "x"}}`, `[`} {
+		if _, err := LoadStaticRates(strings.NewReader(s)); err == nil {
+			t.Errorf("expect error, got nil, rates: %s", s)
+		}
+	}
+}
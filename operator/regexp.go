@@ -0,0 +1,117 @@
+package operator
+
+// The regular expression functions use the syntax of Go regexp, e.g. `matches($email, '^.*@corp\.com$')`,
+// the backslashes in string literals are kept as they are.
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// maxCachedRegexps is the maximum compiled patterns cached by a Context,
+// the patterns from variables are compiled every time once it is full.
+const maxCachedRegexps = 1024
+
+// regexpCache keeps the compiled patterns, it is safe for concurrent use.
+type regexpCache struct {
+	mu sync.RWMutex
+	m  map[string]*regexp.Regexp
+}
+
+func newRegexpCache() *regexpCache {
+	return &regexpCache{m: map[string]*regexp.Regexp{}}
+}
+
+func (c *regexpCache) get(pattern string) (*regexp.Regexp, error) {
+	c.mu.RLock()
+	re, ok := c.m[pattern]
+	c.mu.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.m) < maxCachedRegexps {
+		c.m[pattern] = re
+	}
+	c.mu.Unlock()
+
+	return re, nil
+}
+
+// Regexp returns the compiled pattern, it is cached by the context, so the pattern is compiled once per Manager.
+func (c *Context) Regexp(pattern string) (*regexp.Regexp, error) {
+	if c.regexps == nil {
+		return regexp.Compile(pattern)
+	}
+
+	return c.regexps.get(pattern)
+}
+
+// regexpFunc converts the regular expression function to builtin function, the first two arguments should be
+// the string and the pattern.
+func regexpFunc(f func(re *regexp.Regexp, s string, args []interface{}) (interface{}, error)) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		ss, ok := stringArgs(args[:2])
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+
+		re, err := ctx.Regexp(ss[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArguments, err)
+		}
+
+		return f(re, ss[0], args[2:])
+	}
+}
+
+func matches(re *regexp.Regexp, s string, _ []interface{}) (interface{}, error) {
+	return re.MatchString(s), nil
+}
+
+// extract returns the first match, or its group by index or name with `regex_extract(s, pattern, group)`,
+// it is an empty string if not matched.
+func extract(re *regexp.Regexp, s string, args []interface{}) (interface{}, error) {
+	group := 0
+	if len(args) > 0 {
+		if name, ok := args[0].(string); ok {
+			group = re.SubexpIndex(name)
+		} else if i, ok := toInt(args[0]); ok && i <= int64(re.NumSubexp()) {
+			group = int(i)
+		} else {
+			group = -1
+		}
+		if group < 0 {
+			return nil, ErrInvalidArguments
+		}
+	}
+
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return "", nil
+	}
+
+	return m[group], nil
+}
+
+// regexpReplace replaces all the matches with repl, `$1` or `${name}` in repl is expanded to the group.
+func regexpReplace(re *regexp.Regexp, s string, args []interface{}) (interface{}, error) {
+	repl, ok := args[0].(string)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	return re.ReplaceAllString(s, repl), nil
+}
+
+// matchString reports whether the string s matches the pattern, it is the handler of `s =~ pattern`.
+func matchString(ctx *Context, s, pattern string) (interface{}, error) {
+	return regexpFunc(matches)(ctx, s, pattern)
+}
@@ -0,0 +1,304 @@
+package operator
+
+// The statistics functions accept numbers and lists of numbers, e.g. `avg($list)` or `avg(1,2,3)`,
+// the results are exact in decimal and rational modes except the roots and logarithms.
+
+import (
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/xwjdsh/calc/decimal"
+)
+
+// apply applies the arithmetic operator t on numbers a and b, the non-numbers are invalid arguments.
+func (c *Context) apply(t Token, a, b interface{}) (interface{}, error) {
+	r, ok, err := c.arithmetic(t, a, b)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	return r, err
+}
+
+// quo returns a/b of numbers regardless of the Division of `/`, the quotient of integers is exact,
+// e.g. `avg(1, 2)` is 1.5 even if the integers are truncated by `/`.
+func (c *Context) quo(a, b interface{}) (interface{}, error) {
+	i1, ok1 := toBigInt(a)
+	i2, ok2 := toBigInt(b)
+	if !ok1 || !ok2 {
+		return c.apply(QUO, a, b)
+	}
+	if i2.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+
+	return c.fromRat(new(big.Rat).SetFrac(i1, i2)), nil
+}
+
+// numbers returns the flattened numbers of args and the kind of them, it should not be empty.
+func numbers(args []interface{}) ([]interface{}, numberKind, error) {
+	vs := flatten(args)
+	if len(vs) == 0 {
+		return nil, notNumber, ErrInvalidArguments
+	}
+
+	k := notNumber
+	for _, v := range vs {
+		vk := kindOf(v)
+		if vk == notNumber {
+			return nil, notNumber, ErrInvalidArguments
+		}
+		if vk > k {
+			k = vk
+		}
+	}
+
+	return vs, k, nil
+}
+
+// sorted returns the flattened numbers of args in ascending order, complex numbers are unsupported.
+func sorted(args []interface{}) ([]interface{}, numberKind, error) {
+	vs, k, err := numbers(args)
+	if err != nil {
+		return nil, k, err
+	}
+	if k == complexKind {
+		return nil, k, ErrInvalidArguments
+	}
+
+	vs = append([]interface{}(nil), vs...)
+	sort.SliceStable(vs, func(i, j int) bool {
+		c, _ := compareNumbers(vs[i], vs[j])
+		return c < 0
+	})
+
+	return vs, k, nil
+}
+
+// fold applies the arithmetic operator t on the numbers from left to right.
+func (c *Context) fold(t Token, vs []interface{}) (r interface{}, err error) {
+	r = vs[0]
+	for _, v := range vs[1:] {
+		if r, err = c.apply(t, r, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// count returns the count of numbers, it is 0 for the empty list, e.g. `count($list)`.
+func count(ctx *Context, args ...interface{}) (interface{}, error) {
+	vs := flatten(args)
+	if len(vs) > 0 {
+		if _, _, err := numbers(vs); err != nil {
+			return nil, err
+		}
+	}
+
+	return ctx.Numbers.fromInt(int64(len(vs))), nil
+}
+
+func product(ctx *Context, args ...interface{}) (interface{}, error) {
+	vs, _, err := numbers(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.fold(MUL, vs)
+}
+
+func average(ctx *Context, args ...interface{}) (interface{}, error) {
+	vs, k, err := numbers(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.mean(vs, k)
+}
+
+func (c *Context) mean(vs []interface{}, k numberKind) (interface{}, error) {
+	s, err := c.fold(ADD, vs)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.quo(s, convert(int64(len(vs)), k))
+}
+
+func median(ctx *Context, args ...interface{}) (interface{}, error) {
+	vs, k, err := sorted(args)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(vs)
+	if n%2 == 1 {
+		return vs[n/2], nil
+	}
+
+	return ctx.mean(vs[n/2-1:n/2+1], k)
+}
+
+// mode returns the most frequent number, the smallest one is returned if there are many.
+func mode(_ *Context, args ...interface{}) (interface{}, error) {
+	vs, _, err := sorted(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		r       interface{}
+		best, n int
+	)
+	for i, v := range vs {
+		n++
+		if i > 0 {
+			if c, _ := compareNumbers(v, vs[i-1]); c != 0 {
+				n = 1
+			}
+		}
+		if n > best {
+			r, best = v, n
+		}
+	}
+
+	return r, nil
+}
+
+// variance returns the sample variance function if ddof is 1, or the population variance function if ddof is 0.
+func variance(ddof int) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		vs, k, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+
+		return ctx.variance(vs, k, ddof)
+	}
+}
+
+func (c *Context) variance(vs []interface{}, k numberKind, ddof int) (interface{}, error) {
+	if k == complexKind || len(vs) <= ddof {
+		return nil, ErrInvalidArguments
+	}
+
+	m, err := c.mean(vs, k)
+	if err != nil {
+		return nil, err
+	}
+
+	var s interface{} = convert(int64(0), k)
+	for _, v := range vs {
+		d, err := c.apply(SUB, v, m)
+		if err != nil {
+			return nil, err
+		}
+		if d, err = c.apply(MUL, d, d); err != nil {
+			return nil, err
+		}
+		if s, err = c.apply(ADD, s, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.quo(s, convert(int64(len(vs)-ddof), k))
+}
+
+// stddev returns the sample standard deviation function if ddof is 1, or the population one if ddof is 0.
+func stddev(ddof int) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		vs, k, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := ctx.variance(vs, k, ddof)
+		if err != nil {
+			return nil, err
+		}
+
+		f, _ := toFloat(v)
+		return ctx.fromFloat(math.Sqrt(f), kindOf(v))
+	}
+}
+
+// percentile returns the p-th percentile of the list by linear interpolation between the closest ranks,
+// p is from 0 to 100, e.g. `percentile($list, 50)` equals to `median($list)`.
+func percentile(ctx *Context, args ...interface{}) (interface{}, error) {
+	vs, k, err := sorted(args[:1])
+	if err != nil {
+		return nil, err
+	}
+
+	p := args[1]
+	if c1, ok1 := compareNumbers(p, int64(0)); !ok1 || c1 < 0 {
+		return nil, ErrInvalidArguments
+	}
+	if c2, _ := compareNumbers(p, int64(100)); c2 > 0 {
+		return nil, ErrInvalidArguments
+	}
+
+	// the rank is p/100*(n-1), the exact division is used by multiplying first
+	rank, err := ctx.apply(MUL, p, convert(int64(len(vs)-1), k))
+	if err != nil {
+		return nil, err
+	}
+	if rank, err = ctx.quo(rank, convert(int64(100), k)); err != nil {
+		return nil, err
+	}
+
+	lower, err := roundFunc(math.Floor, decimal.Floor)(ctx, rank)
+	if err != nil {
+		return nil, err
+	}
+	i, _ := toInt(lower)
+	if int(i) == len(vs)-1 {
+		return vs[i], nil
+	}
+
+	// vs[i] + (vs[i+1]-vs[i])*(rank-lower)
+	frac, err := ctx.apply(SUB, rank, lower)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ctx.apply(SUB, vs[i+1], vs[i])
+	if err != nil {
+		return nil, err
+	}
+	if d, err = ctx.apply(MUL, d, frac); err != nil {
+		return nil, err
+	}
+
+	return ctx.apply(ADD, vs[i], d)
+}
+
+// valueRange returns the difference between the maximum and the minimum.
+func valueRange(ctx *Context, args ...interface{}) (interface{}, error) {
+	vs, _, err := sorted(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.apply(SUB, vs[len(vs)-1], vs[0])
+}
+
+// geomean returns the geometric mean of the positive numbers.
+func geomean(ctx *Context, args ...interface{}) (interface{}, error) {
+	vs, k, err := numbers(args)
+	if err != nil || k == complexKind {
+		return nil, ErrInvalidArguments
+	}
+
+	var s float64
+	for _, v := range vs {
+		f, _ := toFloat(v)
+		if f <= 0 {
+			return nil, ErrInvalidArguments
+		}
+		s += math.Log(f)
+	}
+
+	return ctx.fromFloat(math.Exp(s/float64(len(vs))), k)
+}
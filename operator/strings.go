@@ -0,0 +1,350 @@
+package operator
+
+// The string functions work on runes, e.g. `len('héllo')` is 5, the indexes start at 0.
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// stringFunc converts the string function to builtin function, the first argument should be string.
+func stringFunc(f func(ctx *Context, s string, args []interface{}) (interface{}, error)) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+
+		return f(ctx, s, args[1:])
+	}
+}
+
+// stringArgs returns the string arguments.
+func stringArgs(args []interface{}) ([]string, bool) {
+	ss := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, false
+		}
+		ss[i] = s
+	}
+
+	return ss, true
+}
+
+// toString returns the display string of value v, e.g. `1/3` for the rational number.
+func toString(v interface{}) string {
+	switch n := v.(type) {
+	case string:
+		return n
+	case *big.Rat:
+		return n.RatString()
+	case time.Time:
+		return n.Format(time.RFC3339)
+	case []interface{}:
+		ss := make([]string, len(n))
+		for i, e := range n {
+			ss[i] = toString(e)
+		}
+		return strings.Join(ss, ",")
+	}
+
+	return fmt.Sprint(v)
+}
+
+func length(ctx *Context, args ...interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case string:
+		return ctx.Numbers.fromInt(int64(utf8.RuneCountInString(v))), nil
+	case []interface{}:
+		return ctx.Numbers.fromInt(int64(len(v))), nil
+	}
+
+	return nil, ErrInvalidArguments
+}
+
+// trim removes the leading and trailing white spaces, or the characters of cutset by `trim(s, cutset)`.
+func trim(_ *Context, s string, args []interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return strings.TrimSpace(s), nil
+	}
+
+	cutset, ok := args[0].(string)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	return strings.Trim(s, cutset), nil
+}
+
+// substr returns the substring from start with length runes, `substr(s, start, [length])`,
+// the negative start counts from the end, e.g. `substr('hello', -3)` is `llo`.
+func substr(_ *Context, s string, args []interface{}) (interface{}, error) {
+	rs := []rune(s)
+	n := int64(len(rs))
+
+	start, ok := toInt(args[0])
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+	if start < 0 {
+		start += n
+	}
+	start = clamp(start, 0, n)
+
+	end := n
+	if len(args) > 1 {
+		l, ok := toInt(args[1])
+		if !ok || l < 0 {
+			return nil, ErrInvalidArguments
+		}
+		end = clamp(start+l, start, n)
+	}
+
+	return string(rs[start:end]), nil
+}
+
+func clamp(i, min, max int64) int64 {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+
+	return i
+}
+
+// replace replaces all the old strings with new, or the first n ones by `replace(s, old, new, n)`.
+func replace(_ *Context, s string, args []interface{}) (interface{}, error) {
+	ss, ok := stringArgs(args[:2])
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	n := int64(-1)
+	if len(args) > 2 {
+		if n, ok = toInt(args[2]); !ok {
+			return nil, ErrInvalidArguments
+		}
+	}
+
+	return strings.Replace(s, ss[0], ss[1], int(n)), nil
+}
+
+// contains reports whether the string contains the substring, or the list contains the value.
+func contains(ctx *Context, args ...interface{}) (interface{}, error) {
+	i, err := indexOf(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	c, _ := compareNumbers(i, int64(0))
+	return c >= 0, nil
+}
+
+// indexOf returns the rune index of the substring in the string, or the index of the value in the list,
+// it is -1 if not found.
+func indexOf(ctx *Context, args ...interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case string:
+		sub, ok := args[1].(string)
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+
+		i := strings.Index(v, sub)
+		if i >= 0 {
+			i = utf8.RuneCountInString(v[:i])
+		}
+		return ctx.Numbers.fromInt(int64(i)), nil
+	case []interface{}:
+		for i, e := range v {
+			if equal(e, args[1]) {
+				return ctx.Numbers.fromInt(int64(i)), nil
+			}
+		}
+		return ctx.Numbers.fromInt(-1), nil
+	}
+
+	return nil, ErrInvalidArguments
+}
+
+// equal reports whether the values are equal, the numbers of different kinds are compared by value.
+func equal(a, b interface{}) bool {
+	if eq, ok := equalNumbers(a, b); ok {
+		return eq
+	}
+	if c, ok := compareTimes(a, b); ok {
+		return c == 0
+	}
+
+	switch a.(type) {
+	case string, bool:
+		return a == b
+	}
+
+	return false
+}
+
+func hasPrefix(_ *Context, s string, args []interface{}) (interface{}, error) {
+	prefix, ok := args[0].(string)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	return strings.HasPrefix(s, prefix), nil
+}
+
+func hasSuffix(_ *Context, s string, args []interface{}) (interface{}, error) {
+	suffix, ok := args[0].(string)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	return strings.HasSuffix(s, suffix), nil
+}
+
+// split returns the list of substrings separated by sep, `split(s, sep)`.
+func split(_ *Context, s string, args []interface{}) (interface{}, error) {
+	sep, ok := args[0].(string)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	ss := strings.Split(s, sep)
+	r := make([]interface{}, len(ss))
+	for i, e := range ss {
+		r[i] = e
+	}
+
+	return r, nil
+}
+
+// join concatenates the values of list with sep, `join(list, sep)`, the values are converted by str.
+func join(_ *Context, args ...interface{}) (interface{}, error) {
+	sep, ok := args[1].(string)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	vs, ok := args[0].([]interface{})
+	if !ok {
+		vs = []interface{}{args[0]}
+	}
+
+	ss := make([]string, len(vs))
+	for i, v := range vs {
+		ss[i] = toString(v)
+	}
+
+	return strings.Join(ss, sep), nil
+}
+
+// maxPadWidth is the maximum width of pad results in runes.
+const maxPadWidth = 1 << 20
+
+// pad pads the string to width runes with spaces or the given character, `pad(s, width, [char])`,
+// it pads on the left if width is positive, or on the right if width is negative, like the width of `fmt`.
+func pad(_ *Context, s string, args []interface{}) (interface{}, error) {
+	width, ok := toInt(args[0])
+	if !ok || width > maxPadWidth || width < -maxPadWidth {
+		return nil, ErrInvalidArguments
+	}
+
+	char := " "
+	if len(args) > 1 {
+		if char, ok = args[1].(string); !ok || utf8.RuneCountInString(char) != 1 {
+			return nil, ErrInvalidArguments
+		}
+	}
+
+	left := width > 0
+	if !left {
+		width = -width
+	}
+
+	n := width - int64(utf8.RuneCountInString(s))
+	if n <= 0 {
+		return s, nil
+	}
+
+	if left {
+		return strings.Repeat(char, int(n)) + s, nil
+	}
+	return s + strings.Repeat(char, int(n)), nil
+}
+
+// format formats the values by the verbs of `fmt`, e.g. `format('%s: %.2f', 'total', 1/3)`,
+// the numbers are converted to int64 for integer verbs like `%d`, and float64 for float verbs like `%f`.
+func format(_ *Context, s string, args []interface{}) (interface{}, error) {
+	verbs := formatVerbs(s)
+	vs := make([]interface{}, len(args))
+	for i, arg := range args {
+		vs[i] = arg
+		if i >= len(verbs) {
+			continue
+		}
+
+		switch verbs[i] {
+		case 'd', 'x', 'X', 'o', 'b', 'c':
+			if n, ok := toInt(arg); ok {
+				vs[i] = n
+			}
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			if f, ok := toFloat(arg); ok {
+				vs[i] = f
+			}
+		case 's', 'q':
+			vs[i] = toString(arg)
+		}
+	}
+
+	return fmt.Sprintf(s, vs...), nil
+}
+
+// formatVerbs returns the verbs of the format string in order, `%%` is skipped.
+func formatVerbs(s string) []rune {
+	var verbs []rune
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != '%' {
+			continue
+		}
+
+		// skip the flags, width and precision
+		for i++; i < len(rs) && strings.ContainsRune("+-# 0123456789.", rs[i]); i++ {
+		}
+		if i < len(rs) && rs[i] != '%' {
+			verbs = append(verbs, rs[i])
+		}
+	}
+
+	return verbs
+}
+
+// str converts the value to string, e.g. `str(1.5)` is `1.5`.
+func str(_ *Context, args ...interface{}) (interface{}, error) {
+	return toString(args[0]), nil
+}
+
+// num converts the string to number of the context number type, e.g. `num('1.5')`, the numbers are kept.
+func num(ctx *Context, args ...interface{}) (interface{}, error) {
+	if s, ok := args[0].(string); ok {
+		n, err := ctx.Numbers.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArguments, err)
+		}
+		return n, nil
+	}
+
+	if isNumber(args[0]) {
+		return args[0], nil
+	}
+
+	return nil, ErrInvalidArguments
+}
@@ -0,0 +1,255 @@
+package operator
+
+// The time values are time.Time and time.Duration, e.g. `$deadline - now() < days(3)`,
+// the dates without zone are in the location of the context clock.
+
+import (
+	"math"
+	"math/big"
+	"time"
+)
+
+// dateLayouts are the layouts accepted by `date(s)`, in order.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// defaultDateLayout is the layout of `format_date(t)`.
+const defaultDateLayout = "2006-01-02"
+
+// now returns the current time of the context clock, the monotonic clock reading of time.Now is stripped,
+// so it is printed and compared as the wall clock.
+func (c *Context) now() time.Time {
+	if c.Now == nil {
+		return time.Now().Round(0)
+	}
+
+	return c.Now()
+}
+
+// timeArithmetic applies the arithmetic operator t on time values, ok is false if they are unsupported,
+// e.g. time - time is duration, time + duration is time, and duration * number is duration.
+func (c *Context) timeArithmetic(t Token, a, b interface{}) (interface{}, bool, error) {
+	switch x := a.(type) {
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			if t == SUB {
+				return x.Sub(y), true, nil
+			}
+		case time.Duration:
+			switch t {
+			case ADD:
+				return x.Add(y), true, nil
+			case SUB:
+				return x.Add(-y), true, nil
+			}
+		}
+	case time.Duration:
+		switch y := b.(type) {
+		case time.Time:
+			if t == ADD {
+				return y.Add(x), true, nil
+			}
+		case time.Duration:
+			switch t {
+			case ADD:
+				return x + y, true, nil
+			case SUB:
+				return x - y, true, nil
+			case QUO:
+				if y == 0 {
+					return nil, true, ErrDivisionByZero
+				}
+				// the ratio of durations is a number, e.g. `hours(3) / hours(2)` is 1.5
+				r, err := c.quo(int64(x), int64(y))
+				return r, true, err
+			}
+		default:
+			switch t {
+			case MUL:
+				return scaleDuration(x, b, false)
+			case QUO:
+				return scaleDuration(x, b, true)
+			}
+		}
+	default:
+		if y, ok := b.(time.Duration); ok && t == MUL {
+			return scaleDuration(y, a, false)
+		}
+	}
+
+	return nil, false, nil
+}
+
+// scaleDuration returns d*n, or d/n if quo is true, the result is rounded to nanoseconds.
+func scaleDuration(d time.Duration, n interface{}, quo bool) (interface{}, bool, error) {
+	if i, ok := toInt(n); ok {
+		if quo {
+			if i == 0 {
+				return nil, true, ErrDivisionByZero
+			}
+			return d / time.Duration(i), true, nil
+		}
+
+		r := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(i))
+		if !r.IsInt64() {
+			return nil, true, ErrInvalidArguments
+		}
+		return time.Duration(r.Int64()), true, nil
+	}
+
+	f, ok := toFloat(n)
+	if !ok {
+		return nil, false, nil
+	}
+	if quo {
+		if f == 0 {
+			return nil, true, ErrDivisionByZero
+		}
+		f = 1 / f
+	}
+
+	return toDuration(float64(d) * f)
+}
+
+// toDuration converts the nanoseconds f to duration, it is invalid if f overflows.
+func toDuration(f float64) (interface{}, bool, error) {
+	f = math.Round(f)
+	if math.IsNaN(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+		return nil, true, ErrInvalidArguments
+	}
+
+	return time.Duration(f), true, nil
+}
+
+// compareTimes returns -1, 0 or 1 when time or duration a is before or shorter than b, equal, or after or longer.
+func compareTimes(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1, true
+			case x.After(y):
+				return 1, true
+			}
+			return 0, true
+		}
+	case time.Duration:
+		if y, ok := b.(time.Duration); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
+
+func now(ctx *Context, _ ...interface{}) (interface{}, error) {
+	return ctx.now(), nil
+}
+
+// date returns the time by `date('2006-01-02')` or `date(year, month, day)`.
+func date(ctx *Context, args ...interface{}) (interface{}, error) {
+	loc := ctx.now().Location()
+	if s, ok := args[0].(string); ok && len(args) == 1 {
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+				return t, nil
+			}
+		}
+		return nil, ErrInvalidArguments
+	}
+
+	if len(args) != 3 {
+		return nil, ErrInvalidArguments
+	}
+
+	var ymd [3]int
+	for i, arg := range args {
+		n, ok := toInt(arg)
+		if !ok || n > math.MaxInt32 || n < math.MinInt32 {
+			return nil, ErrInvalidArguments
+		}
+		ymd[i] = int(n)
+	}
+
+	return time.Date(ymd[0], time.Month(ymd[1]), ymd[2], 0, 0, 0, 0, loc), nil
+}
+
+// durationFunc returns the function of duration unit, the number is converted to duration,
+// e.g. `days(3)`, and the duration is converted to the number of units, e.g. `hours($d)`.
+func durationFunc(unit time.Duration) builtinFunc {
+	return func(ctx *Context, args ...interface{}) (interface{}, error) {
+		if d, ok := args[0].(time.Duration); ok {
+			return ctx.quo(int64(d), int64(unit))
+		}
+
+		r, ok, err := scaleDuration(unit, args[0], false)
+		if !ok {
+			return nil, ErrInvalidArguments
+		}
+		return r, err
+	}
+}
+
+// timeArg returns the i-th argument as time, it is the current time if absent.
+func timeArg(ctx *Context, args []interface{}, i int) (time.Time, bool) {
+	t, ok := optional(args, i, ctx.now()).(time.Time)
+	return t, ok
+}
+
+// weekday returns the day of the week, 0 is Sunday, `weekday([t])`.
+func weekday(ctx *Context, args ...interface{}) (interface{}, error) {
+	t, ok := timeArg(ctx, args, 0)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	return ctx.Numbers.fromInt(int64(t.Weekday())), nil
+}
+
+// addMonths adds n months to the time, `addmonths(t, n)`, the day is clamped to the end of month like spreadsheets,
+// e.g. `addmonths(date('2026-01-31'), 1)` is 2026-02-28.
+func addMonths(_ *Context, args ...interface{}) (interface{}, error) {
+	t, ok := args[0].(time.Time)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+	n, ok := toInt(args[1])
+	if !ok || n > math.MaxInt32 || n < math.MinInt32 {
+		return nil, ErrInvalidArguments
+	}
+
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1), nil
+}
+
+// formatDate formats the time by the layout of Go time package, `format_date(t, [layout])`,
+// the default layout is `2006-01-02`.
+func formatDate(_ *Context, args ...interface{}) (interface{}, error) {
+	t, ok := args[0].(time.Time)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+	layout, ok := optional(args, 1, defaultDateLayout).(string)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+
+	return t.Format(layout), nil
+}
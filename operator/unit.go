@@ -0,0 +1,503 @@
+package operator
+
+// The quantities are numbers with units of measure, e.g. `3 km + 200 m` is `3.2 km`, the units are checked by
+// their dimensions, so `1 m + 1 s` is ErrIncompatibleUnits. The sum and the converted value are in the unit
+// of the left operand, and the dimensionless result like `1 km / 1 m` is the number in SI units.
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// the indexes of SI base dimensions
+const (
+	dimLength = iota
+	dimMass
+	dimTime
+	dimCurrent
+	dimTemperature
+	dimAmount
+	dimLuminosity
+	dimensionCount
+)
+
+// dimensions are the exponents of SI base dimensions, e.g. the speed is length^1 * time^-1.
+type dimensions [dimensionCount]int
+
+var (
+	lengthDim      = dimensions{dimLength: 1}
+	massDim        = dimensions{dimMass: 1}
+	timeDim        = dimensions{dimTime: 1}
+	currentDim     = dimensions{dimCurrent: 1}
+	temperatureDim = dimensions{dimTemperature: 1}
+	amountDim      = dimensions{dimAmount: 1}
+	luminosityDim  = dimensions{dimLuminosity: 1}
+	areaDim        = dimensions{dimLength: 2}
+	volumeDim      = dimensions{dimLength: 3}
+	speedDim       = dimensions{dimLength: 1, dimTime: -1}
+	frequencyDim   = dimensions{dimTime: -1}
+	forceDim       = dimensions{dimLength: 1, dimMass: 1, dimTime: -2}
+	energyDim      = dimensions{dimLength: 2, dimMass: 1, dimTime: -2}
+	powerDim       = dimensions{dimLength: 2, dimMass: 1, dimTime: -3}
+	pressureDim    = dimensions{dimLength: -1, dimMass: 1, dimTime: -2}
+	chargeDim      = dimensions{dimTime: 1, dimCurrent: 1}
+	voltageDim     = dimensions{dimLength: 2, dimMass: 1, dimTime: -3, dimCurrent: -1}
+	resistanceDim  = dimensions{dimLength: 2, dimMass: 1, dimTime: -3, dimCurrent: -2}
+)
+
+// unitDef is the definition of registered unit, the value of x units is `x*factor+offset` in SI units.
+type unitDef struct {
+	factor *big.Rat
+	// offset is the zero point of temperature scales like degC, it is nil for the others
+	offset *big.Rat
+	dims   dimensions
+}
+
+func newUnitDef(factor string, dims dimensions) unitDef {
+	r, _ := new(big.Rat).SetString(factor)
+	return unitDef{factor: r, dims: dims}
+}
+
+func newAffineUnitDef(factor, offset string, dims dimensions) unitDef {
+	d := newUnitDef(factor, dims)
+	d.offset, _ = new(big.Rat).SetString(offset)
+	return d
+}
+
+// units are the registered units of SI and the common imperial ones, the names are case-sensitive, e.g. `mm` and `Mm`.
+// The inch is `inch` since `in` is the conversion operator.
+var units = map[string]unitDef{
+	// length
+	"m":    newUnitDef("1", lengthDim),
+	"km":   newUnitDef("1000", lengthDim),
+	"cm":   newUnitDef("1/100", lengthDim),
+	"mm":   newUnitDef("1/1000", lengthDim),
+	"um":   newUnitDef("1/1000000", lengthDim),
+	"nm":   newUnitDef("1/1000000000", lengthDim),
+	"inch": newUnitDef("0.0254", lengthDim),
+	"ft":   newUnitDef("0.3048", lengthDim),
+	"yd":   newUnitDef("0.9144", lengthDim),
+	"mi":   newUnitDef("1609.344", lengthDim),
+	"nmi":  newUnitDef("1852", lengthDim),
+
+	// mass
+	"kg": newUnitDef("1", massDim),
+	"g":  newUnitDef("1/1000", massDim),
+	"mg": newUnitDef("1/1000000", massDim),
+	"t":  newUnitDef("1000", massDim),
+	"lb": newUnitDef("0.45359237", massDim),
+	"oz": newUnitDef("0.028349523125", massDim),
+
+	// time
+	"s":   newUnitDef("1", timeDim),
+	"ms":  newUnitDef("1/1000", timeDim),
+	"us":  newUnitDef("1/1000000", timeDim),
+	"ns":  newUnitDef("1/1000000000", timeDim),
+	"min": newUnitDef("60", timeDim),
+	"h":   newUnitDef("3600", timeDim),
+	"d":   newUnitDef("86400", timeDim),
+
+	// electric current, amount of substance and luminous intensity
+	"A":   newUnitDef("1", currentDim),
+	"mA":  newUnitDef("1/1000", currentDim),
+	"mol": newUnitDef("1", amountDim),
+	"cd":  newUnitDef("1", luminosityDim),
+
+	// temperature
+	"K":    newUnitDef("1", temperatureDim),
+	"degC": newAffineUnitDef("1", "273.15", temperatureDim),
+	"degF": newAffineUnitDef("5/9", "45967/180", temperatureDim),
+
+	// area and volume
+	"ha":   newUnitDef("10000", areaDim),
+	"acre": newUnitDef("4046.8564224", areaDim),
+	"L":    newUnitDef("1/1000", volumeDim),
+	"mL":   newUnitDef("1/1000000", volumeDim),
+	"gal":  newUnitDef("0.003785411784", volumeDim),
+
+	// speed
+	"mph": newUnitDef("0.44704", speedDim),
+	"kn":  newUnitDef("463/900", speedDim),
+
+	// derived units
+	"Hz":   newUnitDef("1", frequencyDim),
+	"kHz":  newUnitDef("1000", frequencyDim),
+	"MHz":  newUnitDef("1000000", frequencyDim),
+	"GHz":  newUnitDef("1000000000", frequencyDim),
+	"N":    newUnitDef("1", forceDim),
+	"kN":   newUnitDef("1000", forceDim),
+	"lbf":  newUnitDef("4.4482216152605", forceDim),
+	"J":    newUnitDef("1", energyDim),
+	"kJ":   newUnitDef("1000", energyDim),
+	"MJ":   newUnitDef("1000000", energyDim),
+	"cal":  newUnitDef("4.184", energyDim),
+	"kcal": newUnitDef("4184", energyDim),
+	"Wh":   newUnitDef("3600", energyDim),
+	"kWh":  newUnitDef("3600000", energyDim),
+	"W":    newUnitDef("1", powerDim),
+	"kW":   newUnitDef("1000", powerDim),
+	"MW":   newUnitDef("1000000", powerDim),
+	"hp":   newUnitDef("745.69987158227022", powerDim),
+	"Pa":   newUnitDef("1", pressureDim),
+	"hPa":  newUnitDef("100", pressureDim),
+	"kPa":  newUnitDef("1000", pressureDim),
+	"bar":  newUnitDef("100000", pressureDim),
+	"atm":  newUnitDef("101325", pressureDim),
+	"psi":  newUnitDef("44482216152605/6451600000", pressureDim),
+	"C":    newUnitDef("1", chargeDim),
+	"V":    newUnitDef("1", voltageDim),
+	"mV":   newUnitDef("1/1000", voltageDim),
+	"kV":   newUnitDef("1000", voltageDim),
+	"ohm":  newUnitDef("1", resistanceDim),
+}
+
+// IsUnit reports whether the name is a registered unit, e.g. `km`.
+func IsUnit(name string) bool {
+	_, ok := units[name]
+	return ok
+}
+
+type unitTerm struct {
+	name string
+	exp  int
+}
+
+// Unit is the unit of measure of Quantity, it is a product of the registered units with exponents, e.g. `kg*m/s^2`.
+type Unit struct {
+	terms  []unitTerm
+	factor *big.Rat
+	offset *big.Rat
+	dims   dimensions
+}
+
+// ParseUnit parses the unit expression, the registered units are joined by `*` and `/`,
+// and could have integer exponents, e.g. `m/s^2` or `kg*m^2`.
+func ParseUnit(s string) (Unit, error) {
+	u := Unit{factor: big.NewRat(1, 1)}
+	op, start := byte('*'), 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && s[i] != '*' && s[i] != '/' {
+			continue
+		}
+
+		term := strings.TrimSpace(s[start:i])
+		name, exp := term, 1
+		if j := strings.IndexByte(term, '^'); j >= 0 {
+			n, err := strconv.Atoi(strings.TrimSpace(term[j+1:]))
+			if err != nil || n == 0 {
+				return Unit{}, fmt.Errorf("calc/operator: invalid unit exponent: %s", term)
+			}
+			name, exp = strings.TrimSpace(term[:j]), n
+		}
+		if op == '/' {
+			exp = -exp
+		}
+
+		// `1/s` is the reciprocal of s
+		if !(name == "1" && start == 0 && exp == 1) {
+			d, ok := units[name]
+			if !ok {
+				return Unit{}, fmt.Errorf("calc/operator: unknown unit: %s", name)
+			}
+
+			v, ok := Unit{terms: []unitTerm{{name, 1}}, factor: d.factor, offset: d.offset, dims: d.dims}.pow(exp)
+			if !ok {
+				return Unit{}, fmt.Errorf("%w: %s", ErrIncompatibleUnits, s)
+			}
+			if u, ok = u.mul(v); !ok {
+				return Unit{}, fmt.Errorf("%w: %s", ErrIncompatibleUnits, s)
+			}
+		}
+
+		if i < len(s) {
+			op = s[i]
+		}
+		start = i + 1
+	}
+
+	return u, nil
+}
+
+// pow returns u^n, the units with offset could not be raised to a power except 1.
+func (u Unit) pow(n int) (Unit, bool) {
+	if n == 1 {
+		return u, true
+	}
+	if u.offset != nil {
+		return Unit{}, false
+	}
+
+	r := Unit{factor: ratPow(u.factor, int64(n))}
+	for _, t := range u.terms {
+		if n != 0 {
+			r.terms = append(r.terms, unitTerm{t.name, t.exp * n})
+		}
+	}
+	for i, d := range u.dims {
+		r.dims[i] = d * n
+	}
+
+	return r, true
+}
+
+// mul returns u*v, the exponents of the same units are added, e.g. `m*m` is `m^2`,
+// the units with offset could not be multiplied.
+func (u Unit) mul(v Unit) (Unit, bool) {
+	if len(u.terms) == 0 && u.factor.Cmp(big.NewRat(1, 1)) == 0 {
+		return v, true
+	}
+	if len(v.terms) == 0 && v.factor.Cmp(big.NewRat(1, 1)) == 0 {
+		return u, true
+	}
+	if u.offset != nil || v.offset != nil {
+		return Unit{}, false
+	}
+
+	r := Unit{factor: new(big.Rat).Mul(u.factor, v.factor)}
+	r.terms = append(r.terms, u.terms...)
+	for _, t := range v.terms {
+		merged := false
+		for i := range r.terms {
+			if r.terms[i].name == t.name {
+				r.terms[i].exp += t.exp
+				merged = true
+			}
+		}
+		if !merged {
+			r.terms = append(r.terms, t)
+		}
+	}
+
+	// remove the cancelled units, e.g. `m/m`
+	terms := r.terms[:0]
+	for _, t := range r.terms {
+		if t.exp != 0 {
+			terms = append(terms, t)
+		}
+	}
+	r.terms = terms
+
+	for i := range r.dims {
+		r.dims[i] = u.dims[i] + v.dims[i]
+	}
+
+	return r, true
+}
+
+func (u Unit) dimensionless() bool {
+	return u.dims == dimensions{}
+}
+
+func (u Unit) String() string {
+	var num, den []string
+	for _, t := range u.terms {
+		exp := t.exp
+		if exp < 0 {
+			exp = -exp
+		}
+
+		s := t.name
+		if exp != 1 {
+			s += "^" + strconv.Itoa(exp)
+		}
+
+		if t.exp > 0 {
+			num = append(num, s)
+		} else {
+			den = append(den, s)
+		}
+	}
+
+	if len(num) == 0 {
+		num = []string{"1"}
+	}
+	if len(den) == 0 {
+		return strings.Join(num, "*")
+	}
+
+	return strings.Join(num, "*") + "/" + strings.Join(den, "/")
+}
+
+// Quantity is a number with unit of measure, e.g. `3 km`, Value is a number value.
+type Quantity struct {
+	Value interface{}
+	Unit  Unit
+}
+
+func (q Quantity) String() string {
+	return toString(q.Value) + " " + q.Unit.String()
+}
+
+// unitString returns the unit of v for reporting error, it is `number` for numbers.
+func unitString(v interface{}) string {
+	if q, ok := v.(Quantity); ok {
+		return q.Unit.String()
+	}
+
+	return "number"
+}
+
+// incompatible returns the error of operands a and b in the source order, e.g. `m and s` for `1 m > 1 s`.
+func incompatible(a, b interface{}) error {
+	return fmt.Errorf("%w: %s and %s", ErrIncompatibleUnits, unitString(a), unitString(b))
+}
+
+// quantityArithmetic applies the arithmetic operator t on quantities, or a quantity and a number,
+// ok is false if they are unsupported.
+func (c *Context) quantityArithmetic(t Token, a, b interface{}) (interface{}, bool, error) {
+	qa, okA := a.(Quantity)
+	qb, okB := b.(Quantity)
+	if (!okA && !okB) || (!okA && !isNumber(a)) || (!okB && !isNumber(b)) {
+		return nil, false, nil
+	}
+
+	switch t {
+	case ADD, SUB:
+		// the units with offset are added only if they are the same, e.g. `20 degC + 5 degC`
+		if !okA || !okB || qa.Unit.dims != qb.Unit.dims ||
+			((qa.Unit.offset != nil || qb.Unit.offset != nil) && qa.Unit.String() != qb.Unit.String()) {
+			return nil, true, incompatible(a, b)
+		}
+
+		v, err := c.convertQuantity(qb, qa.Unit)
+		if err != nil {
+			return nil, true, err
+		}
+		r, err := c.apply(t, qa.Value, v)
+		return Quantity{Value: r, Unit: qa.Unit}, true, err
+	case MUL, QUO:
+		u, va, vb := qa.Unit, a, b
+		switch {
+		case okA && okB:
+			v, ok := qb.Unit, true
+			if t == QUO {
+				v, ok = qb.Unit.pow(-1)
+			}
+			if ok {
+				u, ok = qa.Unit.mul(v)
+			}
+			if !ok {
+				return nil, true, incompatible(a, b)
+			}
+			va, vb = qa.Value, qb.Value
+		case okA:
+			va = qa.Value
+		default:
+			u, vb = qb.Unit, qb.Value
+			if t == QUO {
+				var ok bool
+				if u, ok = qb.Unit.pow(-1); !ok {
+					return nil, true, incompatible(a, b)
+				}
+			}
+		}
+
+		r, err := c.apply(t, va, vb)
+		if err != nil {
+			return nil, true, err
+		}
+		return c.quantity(r, u)
+	case CARET, DSTAR:
+		n, ok := toInt(b)
+		if !okA || okB || !ok || n > 1<<16 || n < -1<<16 {
+			return nil, false, nil
+		}
+
+		u, ok := qa.Unit.pow(int(n))
+		if !ok {
+			return nil, true, incompatible(a, b)
+		}
+		r, err := c.apply(t, qa.Value, b)
+		if err != nil {
+			return nil, true, err
+		}
+		return c.quantity(r, u)
+	}
+
+	return nil, false, nil
+}
+
+// quantity returns the quantity of value v with unit u, it is the number in SI units if u is dimensionless.
+func (c *Context) quantity(v interface{}, u Unit) (interface{}, bool, error) {
+	if !u.dimensionless() {
+		return Quantity{Value: v, Unit: u}, true, nil
+	}
+
+	r, err := c.apply(MUL, v, c.fromRat(u.factor))
+	return r, true, err
+}
+
+// convertQuantity returns the value of quantity q in unit u, they should have the same dimensions.
+func (c *Context) convertQuantity(q Quantity, u Unit) (interface{}, error) {
+	if q.Unit.dims != u.dims {
+		return nil, incompatible(q, Quantity{Unit: u})
+	}
+	if q.Unit.String() == u.String() {
+		return q.Value, nil
+	}
+
+	// x*f1+o1 = y*f2+o2, so y = x*(f1/f2) + (o1-o2)/f2
+	ratio := new(big.Rat).Quo(q.Unit.factor, u.factor)
+	r, err := c.apply(MUL, q.Value, c.fromRat(ratio))
+	if err != nil || (q.Unit.offset == nil && u.offset == nil) {
+		return r, err
+	}
+
+	shift := new(big.Rat)
+	if q.Unit.offset != nil {
+		shift.Add(shift, q.Unit.offset)
+	}
+	if u.offset != nil {
+		shift.Sub(shift, u.offset)
+	}
+	return c.apply(ADD, r, c.fromRat(shift.Quo(shift, u.factor)))
+}
+
+// compareQuantities returns -1, 0 or 1 when quantity a is less than, equal to or greater than b,
+// ok is false if none of them is quantity.
+func (c *Context) compareQuantities(a, b interface{}) (int, bool, error) {
+	qa, okA := a.(Quantity)
+	qb, okB := b.(Quantity)
+	if !okA && !okB {
+		return 0, false, nil
+	}
+	if !okA || !okB || qa.Unit.dims != qb.Unit.dims {
+		return 0, true, incompatible(a, b)
+	}
+
+	v, err := c.convertQuantity(qb, qa.Unit)
+	if err != nil {
+		return 0, true, err
+	}
+
+	r, ok := compareNumbers(qa.Value, v)
+	if !ok {
+		return 0, true, ErrInvalidArguments
+	}
+	return r, true, nil
+}
+
+// to converts quantity a to the unit of b, it is the handler of `a to b`, e.g. `60 mph to m/s`,
+// b should be the quantity of one unit.
+func (c *Context) to(a, b interface{}) (interface{}, error) {
+	qb, ok := b.(Quantity)
+	if !ok {
+		return nil, ErrInvalidArguments
+	}
+	if eq, ok := equalNumbers(qb.Value, int64(1)); !ok || !eq {
+		return nil, ErrInvalidArguments
+	}
+
+	qa, ok := a.(Quantity)
+	if !ok {
+		return nil, incompatible(a, b)
+	}
+
+	v, err := c.convertQuantity(qa, qb.Unit)
+	if err != nil {
+		return nil, err
+	}
+	return Quantity{Value: v, Unit: qb.Unit}, nil
+}
@@ -0,0 +1,425 @@
+package calc
+
+import (
+	"math"
+	"strings"
+	"text/scanner"
+
+	"github.com/xwjdsh/calc/ast"
+	"github.com/xwjdsh/calc/operator"
+)
+
+// keywords could not be used as function names.
+const (
+	keywordTrue  = "true"
+	keywordFalse = "false"
+	// `if(cond, a, b)` is the function form of conditional expression
+	keywordIf = "if"
+)
+
+// constants are the predefined identifiers, the names are case-insensitive.
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// imaginarySuffix is the suffix of imaginary number literals, e.g. `2i`.
+const imaginarySuffix = "i"
+
+// parser builds the syntax tree by precedence climbing, the precedence comes from operator.Operator.Preference.
+type parser struct {
+	opManager *operator.Manager
+	input     string
+	s         scanner.Scanner
+
+	// current token
+	tok  rune
+	text string
+	pos  ast.Pos
+
+	// previous token, for reporting error
+	prevText string
+	prevPos  ast.Pos
+
+	// scanErr is the first unterminated literal or comment reported by scanner
+	scanErr error
+}
+
+func newParser(m *operator.Manager, input string) *parser {
+	p := &parser{opManager: m, input: input}
+	p.s.Init(strings.NewReader(input))
+	// only unterminated error matters, e.g. `'hello'` is reported as invalid char literal but it is a string here
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		if p.scanErr == nil && strings.HasSuffix(msg, "not terminated") {
+			p.scanErr = newError(KindSyntax, toPos(s.Position), s.TokenText(), "%s", msg)
+		}
+	}
+	p.next()
+	return p
+}
+
+func toPos(p scanner.Position) ast.Pos {
+	return ast.Pos{Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+func (p *parser) next() {
+	p.prevText, p.prevPos = p.text, p.pos
+	p.tok = p.s.Scan()
+	p.text = p.s.TokenText()
+	p.pos = toPos(p.s.Position)
+
+	// the scanner returns single character, join the following one if they are an operator, e.g. `<=`
+	if len(p.text) == 1 && p.tok == rune(p.text[0]) {
+		if _, ok := p.opManager.GetByString(p.text + string(p.s.Peek())); ok {
+			p.text += string(p.s.Next())
+		}
+	}
+}
+
+// operator returns the operator of current token with given type, function names are case-insensitive.
+func (p *parser) operator(typ operator.Type) (operator.Operator, bool) {
+	if p.tok == scanner.EOF {
+		return nil, false
+	}
+
+	op, ok := p.opManager.GetByString(strings.ToLower(p.text))
+	if !ok || op.Type() != typ {
+		return nil, false
+	}
+
+	return op, true
+}
+
+func (p *parser) parse() (ast.Node, error) {
+	if p.tok == scanner.EOF {
+		return nil, p.errorf("unable to parse empty expressions")
+	}
+
+	node, err := p.parseList()
+	if err == nil && p.tok != scanner.EOF {
+		err = p.unexpected()
+	}
+
+	// the error of scanner is prior, the tokens after it are unreliable
+	if p.scanErr != nil {
+		return nil, p.scanErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// parseList parses the values separated by comma, which has the lowest preference.
+func (p *parser) parseList() (ast.Node, error) {
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.text != operator.COMMA.String() {
+		return node, nil
+	}
+
+	list := &ast.List{Elems: []ast.Node{node}}
+	for p.text == operator.COMMA.String() {
+		p.next()
+		elem, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		list.Elems = append(list.Elems, elem)
+	}
+
+	return list, nil
+}
+
+// parseExpr parses an expression without comma, the conditional expression is right associative,
+// e.g. `a ? b : c ? d : e` equals to `a ? b : (c ? d : e)`.
+func (p *parser) parseExpr() (ast.Node, error) {
+	cond, err := p.parseBinary(1)
+	if err != nil || p.text != "?" {
+		return cond, err
+	}
+
+	pos, text := p.pos, p.text
+	p.next()
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expectClosing(":", pos, text); err != nil {
+		return nil, err
+	}
+
+	els, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Conditional{Cond: cond, Question: pos, Then: then, Else: els}, nil
+}
+
+// parseBinary parses the binary operations whose preference is not less than minPreference,
+// the right operand of a right associative operator could contain the operators with same preference.
+func (p *parser) parseBinary(minPreference int) (ast.Node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.operator(operator.General)
+		if !ok || op.Preference() < minPreference {
+			return x, nil
+		}
+
+		next := op.Preference() + 1
+		if op.Associativity() == operator.RightAssociative {
+			next = op.Preference()
+		}
+
+		pos := p.pos
+		p.next()
+		y, err := p.parseBinary(next)
+		if err != nil {
+			return nil, err
+		}
+
+		x = &ast.Binary{X: x, OpPos: pos, Op: op.Token().String(), Y: y}
+	}
+}
+
+func (p *parser) parseUnary() (ast.Node, error) {
+	op, ok := p.operator(operator.Unary)
+	if p.text == operator.SUB.String() {
+		// `-` as prefix is the opposite number function
+		op, ok = p.opManager.Get(operator.OPP)
+	}
+	if !ok {
+		return p.parsePrimary()
+	}
+
+	pos, text := p.pos, p.text
+	p.next()
+	x, err := p.parseBinary(op.Preference())
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Unary{OpPos: pos, Op: text, X: x}, nil
+}
+
+func (p *parser) parsePrimary() (ast.Node, error) {
+	pos, text := p.pos, p.text
+	switch {
+	case p.tok == scanner.Float || p.tok == scanner.Int:
+		p.next()
+		// the imaginary suffix follows the number immediately, e.g. `2i`
+		if p.tok == scanner.Ident && p.text == imaginarySuffix && p.pos.Offset == pos.Offset+len(text) {
+			text += p.text
+			p.next()
+		}
+		return p.parsePostfixUnit(&ast.Number{ValuePos: pos, Literal: text})
+	case p.tok == scanner.Char || p.tok == scanner.String:
+		p.next()
+		// remove surrounding double or single quotes
+		return &ast.String{ValuePos: pos, Value: text[1 : len(text)-1]}, nil
+	case p.tok == scanner.Ident && (strings.EqualFold(text, keywordTrue) || strings.EqualFold(text, keywordFalse)):
+		p.next()
+		return &ast.Bool{ValuePos: pos, Value: strings.EqualFold(text, keywordTrue)}, nil
+	case p.tok == scanner.Ident && isConstant(text) && p.s.Peek() != '(':
+		p.next()
+		return &ast.Constant{NamePos: pos, Name: strings.ToLower(text)}, nil
+	case p.isUnit():
+		return p.parseUnit()
+	case text == "$":
+		p.next()
+		if p.tok != scanner.Ident && p.tok != scanner.Int {
+			return nil, p.errorf("invalid variable name: '%s'", p.text)
+		}
+
+		name := p.text
+		p.next()
+		return p.parsePostfixUnit(&ast.Variable{Dollar: pos, Name: name})
+	case text == operator.LPAREN.String():
+		p.next()
+		if p.text == operator.RPAREN.String() {
+			return nil, p.errorf("empty parentheses")
+		}
+
+		node, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectClosing(operator.RPAREN.String(), pos, text); err != nil {
+			return nil, err
+		}
+		return p.parsePostfixUnit(node)
+	}
+
+	if _, ok := p.operator(operator.Function); ok || (p.tok == scanner.Ident && strings.EqualFold(text, keywordIf)) {
+		return p.parseCall()
+	}
+
+	switch {
+	case p.tok == scanner.EOF || text == operator.RPAREN.String() || text == ":":
+		if p.prevText == "" {
+			return nil, p.errorf("missing operand before '%s'", text)
+		}
+		return nil, newError(KindSyntax, p.prevPos, p.prevText, "missing operand after '%s'", p.prevText)
+	case p.tok == scanner.Ident && p.s.Peek() == '(':
+		return nil, p.errorf("unknown function: '%s'", text)
+	case p.tok == scanner.Ident:
+		return nil, p.errorf("unknown identifier: '%s', variable should be prefixed with '$'", text)
+	}
+
+	if _, ok := p.opManager.GetByString(text); ok || text == "?" {
+		return nil, p.errorf("missing operand before '%s'", text)
+	}
+
+	return nil, p.errorf("unsupported token: '%s'", text)
+}
+
+// isUnit reports whether the current token is a unit or currency, the function call like `min(1,2)` is not.
+func (p *parser) isUnit() bool {
+	return p.tok == scanner.Ident && (operator.IsUnit(p.text) || operator.IsCurrency(p.text)) && p.nextChar() != '('
+}
+
+// nextChar returns the first character after current token except white spaces, or EOF.
+func (p *parser) nextChar() rune {
+	for _, r := range p.input[p.s.Pos().Offset:] {
+		if !strings.ContainsRune(" \t\r\n", r) {
+			return r
+		}
+	}
+
+	return scanner.EOF
+}
+
+// parseUnit parses the unit with optional integer exponent, e.g. `km` or `s^-2`, the currency has no exponent.
+func (p *parser) parseUnit() (ast.Node, error) {
+	unit := &ast.Unit{NamePos: p.pos, Name: p.text}
+	p.next()
+	if p.text != operator.CARET.String() || operator.IsCurrency(unit.Name) {
+		return unit, nil
+	}
+
+	p.next()
+	if p.text == operator.SUB.String() {
+		unit.Name += "^-"
+		p.next()
+	} else {
+		unit.Name += "^"
+	}
+	if p.tok != scanner.Int {
+		return nil, p.errorf("invalid exponent of unit '%s': '%s'", unit.Name, p.text)
+	}
+
+	unit.Name += p.text
+	p.next()
+	return unit, nil
+}
+
+// parsePostfixUnit parses the unit after the operand x, e.g. `3 km` equals to `3 * km`.
+func (p *parser) parsePostfixUnit(x ast.Node) (ast.Node, error) {
+	if !p.isUnit() {
+		return x, nil
+	}
+
+	unit, err := p.parseUnit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.Binary{X: x, OpPos: unit.Pos(), Op: operator.MUL.String(), Y: unit}, nil
+}
+
+func isConstant(name string) bool {
+	_, ok := constants[strings.ToLower(name)]
+	return ok
+}
+
+func (p *parser) parseCall() (ast.Node, error) {
+	call := &ast.Call{NamePos: p.pos, Name: strings.ToLower(p.text)}
+	p.next()
+	if p.text != operator.LPAREN.String() {
+		return nil, newError(KindSyntax, call.NamePos, call.Name, "missing '(' after function '%s'", call.Name)
+	}
+
+	lparen := p.pos
+	p.next()
+	if p.text == operator.RPAREN.String() {
+		if min := p.minArgs(call.Name); min > 0 {
+			return nil, newError(KindArity, call.NamePos, call.Name, "empty call of function '%s', it requires at least %d argument(s)", call.Name, min)
+		}
+	} else {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+
+			if p.text != operator.COMMA.String() {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if err := p.expectClosing(operator.RPAREN.String(), lparen, operator.LPAREN.String()); err != nil {
+		return nil, err
+	}
+
+	return call, nil
+}
+
+func (p *parser) minArgs(name string) int {
+	if name == keywordIf {
+		return 3
+	}
+
+	op, _ := p.opManager.GetByString(name)
+	if eop, ok := op.(operator.ExecutableOperator); ok {
+		min, _ := eop.Arity()
+		return min
+	}
+
+	return 0
+}
+
+// expectClosing consumes the closing token t, which matches the opening token at pos.
+func (p *parser) expectClosing(t string, pos ast.Pos, opening string) error {
+	if p.text == t && p.tok != scanner.EOF {
+		p.next()
+		return nil
+	}
+
+	if p.tok == scanner.EOF {
+		return newError(KindSyntax, pos, opening, "missing '%s' for '%s'", t, opening)
+	}
+
+	return p.unexpected()
+}
+
+// unexpected returns the error of current token, which should be an operator.
+func (p *parser) unexpected() error {
+	switch {
+	case p.text == operator.RPAREN.String():
+		return p.errorf("unmatched ')'")
+	case p.tok == scanner.Int || p.tok == scanner.Float || p.tok == scanner.String || p.tok == scanner.Char ||
+		p.tok == scanner.Ident || p.text == "$" || p.text == operator.LPAREN.String():
+		return p.errorf("missing operator between '%s' and '%s'", p.prevText, p.text)
+	}
+
+	return p.errorf("unexpected token: '%s'", p.text)
+}
+
+// errorf returns the syntax error at current token.
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return newError(KindSyntax, p.pos, p.text, format, args...)
+}
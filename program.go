@@ -0,0 +1,382 @@
+package calc
+
+import (
+	"strings"
+
+	"github.com/xwjdsh/calc/ast"
+	"github.com/xwjdsh/calc/decimal"
+	"github.com/xwjdsh/calc/operator"
+	"github.com/xwjdsh/calc/stack"
+)
+
+type instructionType int
+
+const (
+	// push a constant value
+	pushValue instructionType = iota
+	// push the value of a variable
+	pushVariable
+	// execute an operator with the values on the top of stack
+	executeOperator
+	// jump to target
+	jump
+	// pop the condition, jump to target if it is false
+	jumpIfFalse
+)
+
+type instruction struct {
+	typ   instructionType
+	value interface{}
+	name  string
+	op    operator.ExecutableOperator
+	// argc is the arguments count of op
+	argc int
+	// target is the instruction index to jump
+	target int
+	// pos and token are the source of instruction, for reporting error
+	pos   ast.Pos
+	token string
+}
+
+// Program is the compiled form of expressions, in reverse polish notation.
+// It is immutable once compiled, so it could be evaluated many times, also concurrently.
+type Program struct {
+	instructions []instruction
+	options      options
+}
+
+// Eval evaluates the program with given variables, the evaluation state is per call.
+func (p *Program) Eval(m map[string]interface{}) (interface{}, error) {
+	params := stack.New()
+	for pc := 0; pc < len(p.instructions); pc++ {
+		ins := p.instructions[pc]
+		switch ins.typ {
+		case pushValue:
+			params.Push(ins.value)
+		case pushVariable:
+			v, ok := p.lookup(m, ins.name)
+			if !ok {
+				return nil, newError(KindUnknownVariable, ins.pos, ins.token, "unknown variable: %s", ins.name)
+			}
+
+			nv, ok := convertAndValidation(v, p.options.numbers)
+			if !ok {
+				return nil, newError(KindTypeMismatch, ins.pos, ins.token, "unsupported variable type, name: %s, type: %T", ins.name, v)
+			}
+			params.Push(nv)
+		case executeOperator:
+			args := make([]interface{}, ins.argc)
+			for i := ins.argc - 1; i >= 0; i-- {
+				args[i], _ = params.Pop()
+			}
+
+			result, err := ins.op.Execute(args)
+			if err != nil {
+				return nil, wrapError(err, ins.pos, ins.token)
+			}
+			params.Push(result)
+		case jump:
+			pc = ins.target - 1
+		case jumpIfFalse:
+			v, _ := params.Pop()
+			cond, ok := v.(bool)
+			if !ok {
+				return nil, newError(KindTypeMismatch, ins.pos, ins.token, "condition should be bool, got: %v", v)
+			}
+			if !cond {
+				pc = ins.target - 1
+			}
+		}
+	}
+
+	result, _ := params.Top()
+	return result, nil
+}
+
+func (p *Program) lookup(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok || !p.options.caseInsensitiveVariables {
+		return v, ok
+	}
+
+	if v, ok := m[strings.ToLower(name)]; ok {
+		return v, ok
+	}
+
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// compiler translates the syntax tree into a Program.
+type compiler struct {
+	opManager *operator.Manager
+	program   *Program
+}
+
+func newCompiler(m *operator.Manager, opts options) *compiler {
+	return &compiler{
+		opManager: m,
+		program:   &Program{options: opts},
+	}
+}
+
+func (c *compiler) compile(node ast.Node) (*Program, error) {
+	if err := c.compileNode(node); err != nil {
+		return nil, err
+	}
+
+	return c.program, nil
+}
+
+func (c *compiler) compileNode(node ast.Node) error {
+	switch n := node.(type) {
+	case *ast.Number:
+		v, err := c.program.options.numbers.Parse(n.Literal)
+		if err != nil {
+			return newError(KindSyntax, n.ValuePos, n.Literal, "invalid number: %s", n.Literal)
+		}
+		c.pushValue(v)
+	case *ast.Constant:
+		c.pushValue(c.constantValue(constants[n.Name]))
+	case *ast.Unit:
+		if operator.IsCurrency(n.Name) {
+			c.pushValue(operator.Money{Amount: decimal.New(1, 0), Currency: n.Name})
+			return nil
+		}
+
+		u, err := operator.ParseUnit(n.Name)
+		if err != nil {
+			return newError(KindSyntax, n.NamePos, n.Name, "invalid unit: %s", n.Name)
+		}
+		one, _ := c.program.options.numbers.Parse("1")
+		c.pushValue(operator.Quantity{Value: one, Unit: u})
+	case *ast.String:
+		c.pushValue(n.Value)
+	case *ast.Bool:
+		c.pushValue(n.Value)
+	case *ast.Variable:
+		c.emit(instruction{typ: pushVariable, name: n.Name, pos: n.Dollar, token: "$" + n.Name})
+	case *ast.Binary:
+		switch n.Op {
+		case operator.LAND.String():
+			// `a && b` -> `a ? true && b : false`, b is validated by the operator
+			return c.compileConditional(n.OpPos, n.Op, n.X, c.shortCircuit(true, n), c.value(false))
+		case operator.LOR.String():
+			// `a || b` -> `a ? true : false || b`
+			return c.compileConditional(n.OpPos, n.Op, n.X, c.value(true), c.shortCircuit(false, n))
+		case operator.TO.String(), operator.IN.String():
+			if u, ok := n.Y.(*ast.Unit); ok && operator.IsCurrency(u.Name) {
+				return c.compileExchange(n.X, n)
+			}
+		}
+
+		if err := c.compilePatternNodes(n.Op, n.X, n.Y); err != nil {
+			return err
+		}
+		return c.executeOperator(n.Op, 2, n.OpPos, n.Op)
+	case *ast.Unary:
+		if err := c.compileNode(n.X); err != nil {
+			return err
+		}
+		if n.Op == operator.SUB.String() {
+			// prefix `-` is the opposite number function
+			return c.executeOperator(operator.OPP.String(), 1, n.OpPos, n.Op)
+		}
+		return c.executeOperator(n.Op, 1, n.OpPos, n.Op)
+	case *ast.Conditional:
+		return c.compileConditional(n.Question, "?", n.Cond, c.node(n.Then), c.node(n.Else))
+	case *ast.Call:
+		if n.Name == keywordIf {
+			if len(n.Args) != 3 {
+				return newError(KindArity, n.NamePos, n.Name, "invalid param count for operator: %s, expected: 3, actual: %d", n.Name, len(n.Args))
+			}
+			return c.compileConditional(n.NamePos, n.Name, n.Args[0], c.node(n.Args[1]), c.node(n.Args[2]))
+		}
+
+		if err := c.compilePatternNodes(n.Name, n.Args...); err != nil {
+			return err
+		}
+		return c.executeOperator(n.Name, len(n.Args), n.NamePos, n.Name)
+	case *ast.List:
+		return c.compileList(n.Elems)
+	default:
+		return newError(KindSyntax, node.Pos(), node.String(), "unsupported node: %T", node)
+	}
+
+	return nil
+}
+
+func (c *compiler) compileNodes(nodes ...ast.Node) error {
+	for _, n := range nodes {
+		if err := c.compileNode(n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// patternArgs are the argument indexes of the regular expression patterns,
+// the literal patterns are compiled in advance, so the invalid ones are reported with position.
+var patternArgs = map[string]int{
+	operator.MATCH.String():         1,
+	operator.MATCHES.String():       1,
+	operator.REGEX_EXTRACT.String(): 1,
+	operator.REGEX_REPLACE.String(): 1,
+}
+
+// compilePatternNodes compiles the arguments of operator code, and validates the literal pattern if it has.
+func (c *compiler) compilePatternNodes(code string, nodes ...ast.Node) error {
+	if err := c.compileNodes(nodes...); err != nil {
+		return err
+	}
+
+	if i, ok := patternArgs[code]; ok && i < len(nodes) {
+		if s, ok := nodes[i].(*ast.String); ok {
+			if _, err := c.opManager.Context().Regexp(s.Value); err != nil {
+				return newError(KindSyntax, s.ValuePos, s.String(), "invalid regular expression: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compileExchange converts the operands of sum to the currency of conv before adding them, since the money
+// of different currencies could not be added, e.g. `(1 USD + 1 EUR) in GBP` -> `(1 USD in GBP) + (1 EUR in GBP)`.
+func (c *compiler) compileExchange(x ast.Node, conv *ast.Binary) error {
+	switch n := x.(type) {
+	case *ast.Binary:
+		if n.Op == operator.ADD.String() || n.Op == operator.SUB.String() {
+			if err := c.compileExchange(n.X, conv); err != nil {
+				return err
+			}
+			if err := c.compileExchange(n.Y, conv); err != nil {
+				return err
+			}
+			return c.executeOperator(n.Op, 2, n.OpPos, n.Op)
+		}
+	case *ast.Unary:
+		if n.Op == operator.SUB.String() {
+			if err := c.compileExchange(n.X, conv); err != nil {
+				return err
+			}
+			return c.executeOperator(operator.OPP.String(), 1, n.OpPos, n.Op)
+		}
+	}
+
+	if err := c.compileNodes(x, conv.Y); err != nil {
+		return err
+	}
+	return c.executeOperator(conv.Op, 2, conv.OpPos, conv.Op)
+}
+
+// compileConditional evaluates only one of then and els by cond, pos and token are the source of condition.
+func (c *compiler) compileConditional(pos ast.Pos, token string, cond ast.Node, then, els func() error) error {
+	if err := c.compileNode(cond); err != nil {
+		return err
+	}
+	jumpToElse := c.emit(instruction{typ: jumpIfFalse, pos: pos, token: token})
+
+	if err := then(); err != nil {
+		return err
+	}
+	jumpToEnd := c.emit(instruction{typ: jump})
+
+	c.program.instructions[jumpToElse].target = len(c.program.instructions)
+	if err := els(); err != nil {
+		return err
+	}
+	c.program.instructions[jumpToEnd].target = len(c.program.instructions)
+
+	return nil
+}
+
+func (c *compiler) node(n ast.Node) func() error {
+	return func() error { return c.compileNode(n) }
+}
+
+func (c *compiler) value(v interface{}) func() error {
+	return func() error {
+		c.pushValue(v)
+		return nil
+	}
+}
+
+// shortCircuit executes the boolean operator with the known left operand v.
+func (c *compiler) shortCircuit(v bool, n *ast.Binary) func() error {
+	return func() error {
+		c.pushValue(v)
+		if err := c.compileNode(n.Y); err != nil {
+			return err
+		}
+		return c.executeOperator(n.Op, 2, n.OpPos, n.Op)
+	}
+}
+
+// emit appends the instruction and returns its index.
+func (c *compiler) emit(ins instruction) int {
+	c.program.instructions = append(c.program.instructions, ins)
+	return len(c.program.instructions) - 1
+}
+
+// compileList joins the values by comma operator.
+func (c *compiler) compileList(nodes []ast.Node) error {
+	for i, n := range nodes {
+		if err := c.compileNode(n); err != nil {
+			return err
+		}
+
+		if i > 0 {
+			if err := c.executeOperator(operator.COMMA.String(), 2, n.Pos(), operator.COMMA.String()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *compiler) pushValue(v interface{}) {
+	c.emit(instruction{typ: pushValue, value: v})
+}
+
+// executeOperator appends the operator of code, pos and token are its source in expressions.
+func (c *compiler) executeOperator(code string, argc int, pos ast.Pos, token string) error {
+	op, ok := c.opManager.GetByString(code)
+	if !ok {
+		return newError(KindSyntax, pos, token, "unsupported token: '%s'", token)
+	}
+
+	eop, ok := op.(operator.ExecutableOperator)
+	if !ok {
+		return newError(KindSyntax, pos, token, "unexecutable operator: %s", op.Token())
+	}
+
+	min, max := eop.Arity()
+	// a single argument may be a list which is spread to arguments, it is checked at runtime
+	spreadable := argc == 1 && (max == operator.Variadic || min > 1)
+	if !spreadable && (argc < min || (max != operator.Variadic && argc > max)) {
+		return newError(KindArity, pos, token, "invalid param count for operator: %s, actual: %d", op.Token(), argc)
+	}
+
+	c.emit(instruction{typ: executeOperator, op: eop, argc: argc, pos: pos, token: token})
+	return nil
+}
+
+// constantValue converts the constant to the number type, it keeps float64 unless the type is decimal,
+// since the constants are irrational. The decimal constant is rounded like the inexact results.
+func (c *compiler) constantValue(f float64) interface{} {
+	if c.program.options.numbers == operator.DecimalNumbers {
+		if d, err := decimal.NewFromFloat(f); err == nil {
+			ctx := c.opManager.Context()
+			return d.Round(ctx.DecimalPrecision, ctx.Rounding)
+		}
+	}
+
+	return f
+}